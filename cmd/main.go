@@ -2,27 +2,117 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/dynamic"
+	kubeinformer "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 
+	"github.com/cyhw/mysql-operator/pkg/admission"
 	crclientset "github.com/cyhw/mysql-operator/pkg/clients/clientset/versioned"
 	crinformer "github.com/cyhw/mysql-operator/pkg/clients/informers/externalversions"
 	crcontroller "github.com/cyhw/mysql-operator/pkg/controller"
+	"github.com/cyhw/mysql-operator/pkg/conversion"
+	"github.com/cyhw/mysql-operator/pkg/leader"
+	"github.com/cyhw/mysql-operator/pkg/metrics"
+	"github.com/cyhw/mysql-operator/pkg/validation"
+	"github.com/cyhw/mysql-operator/pkg/version"
 )
 
-var kubeconfig string
+var (
+	kubeconfig         string
+	metricsAddr        string
+	webhookAddr        string
+	webhookCertFile    string
+	webhookKeyFile     string
+	printVersion       bool
+	logFormat          string
+	emitVAP            bool
+	enablePprof        bool
+	statusResyncPeriod time.Duration
+	replicationLag     time.Duration
+	syncTimeout        time.Duration
+	readinessInitial   time.Duration
+	readinessMax       time.Duration
+	versionsConfigMap  string
+	breakerThreshold   int
+	breakerCooldown    time.Duration
+	maxReplicas        int
+	disableStatus      bool
+	kubeAPIQPS         float64
+	kubeAPIBurst       int
+	leaderElect        bool
+	leaderElectNS      string
+	leaderElectName    string
+	watchNamespace     string
+)
 
 func init() {
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "filepath to the kubeconfig file")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "address the metrics endpoint binds to, empty to disable")
+	flag.StringVar(&webhookAddr, "webhook-bind-address", "", "address the conversion and validating webhooks bind to, empty to disable")
+	flag.StringVar(&webhookCertFile, "webhook-cert-file", "", "TLS certificate of the webhooks")
+	flag.StringVar(&webhookKeyFile, "webhook-key-file", "", "TLS key of the webhooks")
+	flag.BoolVar(&printVersion, "version", false, "print the version and exit")
+	flag.StringVar(&logFormat, "log-format", "text", "format of the logs, text or json")
+	flag.BoolVar(&enablePprof, "enable-pprof", false, "serve the net/http/pprof handlers under /debug/pprof/ on the metrics address")
+	flag.BoolVar(&emitVAP, "emit-vap", false, "print a ValidatingAdmissionPolicy enforcing the MySQL constraints and exit")
+	flag.DurationVar(&statusResyncPeriod, "status-resync-period", 0, "average period of the jittered status resync of every instance, 0 to disable")
+	flag.DurationVar(&replicationLag, "max-replication-lag", 30*time.Second, "replication lag above which an instance is reported Degraded and not Available")
+	flag.DurationVar(&syncTimeout, "sync-timeout", 2*time.Minute, "time to wait for the informer caches to sync before exiting with an error, 0 to wait forever")
+	flag.DurationVar(&readinessInitial, "readiness-backoff-initial", time.Second, "first delay of the polls of an instance until its pods are ready, 0 to disable")
+	flag.DurationVar(&readinessMax, "readiness-backoff-max", time.Minute, "longest delay of the polls of an instance until its pods are ready")
+	flag.StringVar(&versionsConfigMap, "versions-configmap", "", "namespace/name of the ConfigMap listing, per flavor, the versions spec.autoMinorUpgrade may move to")
+	flag.IntVar(&breakerThreshold, "breaker-threshold", 10, "reconciles in a row failing to reach the API server after which all reconciles pause, 0 to disable")
+	flag.DurationVar(&breakerCooldown, "breaker-cooldown", 30*time.Second, "time reconciles pause once the API server is deemed unreachable")
+	flag.IntVar(&maxReplicas, "max-replicas", 0, "highest spec.replicas an instance may request, above which it is reported Degraded and not scaled, 0 for no limit")
+	flag.BoolVar(&disableStatus, "disable-status-updates", false, "reconcile without writing the status of the custom resources, e.g. to observe a shadow operator; restores and blue/green upgrades are not run")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", float64(rest.DefaultQPS), "queries per second to the API server")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", rest.DefaultBurst, "burst of queries to the API server")
+	flag.BoolVar(&leaderElect, "leader-elect", false, "elect a leader among the replicas of the operator, only the leader reconciles")
+	flag.StringVar(&leaderElectNS, "leader-elect-namespace", os.Getenv("POD_NAMESPACE"), "namespace of the leader election Lease, defaults to $POD_NAMESPACE")
+	flag.StringVar(&leaderElectName, "leader-elect-name", "mysql-operator", "name of the leader election Lease")
+	flag.StringVar(&watchNamespace, "namespace", "", "namespace of the MySQLs the operator manages, empty for all namespaces; the ConfigMap of --versions-configmap must be in it")
 }
 
 func main() {
 	klog.InitFlags(nil)
 	flag.Parse()
+	setupLogging(logFormat)
+
+	info := version.Get()
+	if printVersion {
+		fmt.Println(info)
+		return
+	}
+	if emitVAP {
+		if err := validation.WritePolicy(os.Stdout); err != nil {
+			klog.Fatalf("Failed to write policy: %s", err)
+		}
+		return
+	}
+	klog.InfoS("Start operator.", "version", info.Version, "gitCommit", info.GitCommit, "goVersion", info.GoVersion)
+
+	buildInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mysql_operator_build_info",
+		Help: "Build information of the operator.",
+	}, []string{"version", "git_commit", "go_version"})
+	buildInfo.WithLabelValues(info.Version, info.GitCommit, info.GoVersion).Set(1)
+	metrics.MustRegister(buildInfo)
+	metrics.RegisterClientMetrics()
 
 	var cfg *rest.Config
 	var err error
@@ -34,6 +124,8 @@ func main() {
 	if err != nil {
 		klog.Fatalf("Failed to build kubeconfig: %s", err)
 	}
+	cfg.QPS = float32(kubeAPIQPS)
+	cfg.Burst = kubeAPIBurst
 
 	k8sClient, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
@@ -45,15 +137,143 @@ func main() {
 		klog.Fatalf("Failed to build custom resource client: %s", err)
 	}
 
-	crInformerFactory := crinformer.NewSharedInformerFactory(crClient, 0)
-	ctrl := crcontroller.NewController(k8sClient, crClient, crInformerFactory.Volc().V1alpha1().MySQLs())
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("Failed to build dynamic client: %s", err)
+	}
+
+	crInformerFactory := crinformer.NewSharedInformerFactoryWithOptions(crClient, 0,
+		crinformer.WithNamespace(watchNamespace))
+	kubeInformerFactory := kubeinformer.NewSharedInformerFactoryWithOptions(k8sClient, 0,
+		kubeinformer.WithNamespace(watchNamespace))
+	managedInformerFactory := kubeinformer.NewSharedInformerFactoryWithOptions(k8sClient, 0,
+		kubeinformer.WithNamespace(watchNamespace),
+		kubeinformer.WithTweakListOptions(crcontroller.ManagedListOptions))
+	ctrl := crcontroller.NewController(k8sClient, crClient, dynamicClient,
+		crInformerFactory.Volc().V1alpha1().MySQLs(),
+		crInformerFactory.Volc().V1alpha1().MySQLRestores(),
+		kubeInformerFactory.Apps().V1().StatefulSets(),
+		kubeInformerFactory.Batch().V1().CronJobs(),
+		managedInformerFactory.Core().V1().Pods(),
+		managedInformerFactory.Core().V1().Secrets(),
+		managedInformerFactory.Core().V1().Services(),
+		managedInformerFactory.Core().V1().PersistentVolumeClaims(),
+		kubeInformerFactory.Networking().V1().NetworkPolicies(),
+		kubeInformerFactory.Core().V1().ConfigMaps(),
+		managedInformerFactory.Apps().V1().Deployments(),
+		managedInformerFactory.Batch().V1().Jobs(),
+		crcontroller.Options{
+			StatusResyncPeriod:      statusResyncPeriod,
+			MaxReplicationLag:       replicationLag,
+			SyncTimeout:             syncTimeout,
+			ReadinessBackoffInitial: readinessInitial,
+			ReadinessBackoffMax:     readinessMax,
+			VersionsConfigMap:       versionsConfigMap,
+			BreakerThreshold:        breakerThreshold,
+			BreakerCooldown:         breakerCooldown,
+			MaxReplicas:             int32(maxReplicas),
+			DisableStatusUpdates:    disableStatus,
+			Namespace:               watchNamespace,
+		})
 
-	ctx := context.TODO()
-	crInformerFactory.Start(ctx.Done())
+	metrics.MustRegister(ctrl.InstanceCollector())
 
-	err = ctrl.Run(ctx.Done())
+	identity, err := os.Hostname()
 	if err != nil {
-		klog.Fatalf("Failed to run controller: %s", err)
+		klog.Fatalf("Failed to get hostname: %s", err)
+	}
+	elector := leader.NewElector(identity)
+	if metricsAddr != "" {
+		go serveMetrics(metricsAddr, enablePprof, elector)
+	}
+	// Every replica serves the webhooks, the API server may call any of them.
+	if webhookAddr != "" {
+		go serveWebhook(webhookAddr, webhookCertFile, webhookKeyFile)
+	}
+
+	run := func(ctx context.Context) {
+		crInformerFactory.Start(ctx.Done())
+		kubeInformerFactory.Start(ctx.Done())
+		managedInformerFactory.Start(ctx.Done())
+
+		err := ctrl.Run(ctx.Done())
+		if err != nil {
+			klog.Fatalf("Failed to run controller: %s", err)
+		}
+	}
+	ctx := context.TODO()
+	if leaderElect {
+		if leaderElectNS == "" {
+			klog.Fatalf("--leader-elect-namespace is required with --leader-elect outside of a pod")
+		}
+		err = elector.Run(ctx, k8sClient, leaderElectNS, leaderElectName, run)
+		if err != nil {
+			klog.Fatalf("Failed to run leader election: %s", err)
+		}
+	} else {
+		elector.RunAlone(ctx, run)
 	}
 	klog.InfoS("Exit.")
 }
+
+// setupLogging switches klog to one JSON object per line when format is json.
+// The verbosity is still set by -v.
+func setupLogging(format string) {
+	switch format {
+	case "text":
+	case "json":
+		verbosity, _ := strconv.Atoi(flag.Lookup("v").Value.String())
+		klog.SetLogger(funcr.NewJSON(func(obj string) {
+			fmt.Fprintln(os.Stderr, obj)
+		}, funcr.Options{
+			LogTimestamp: true,
+			Verbosity:    verbosity,
+		}))
+	default:
+		klog.Fatalf("Unknown log format %q, expected text or json", format)
+	}
+}
+
+// serveMetrics serves the metrics, the health checks and the leader election
+// status. Standbys are ready so they can take over, /status tells them apart.
+func serveMetrics(addr string, pprofEnabled bool, elector *leader.Elector) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := elector.Ready(r); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(elector.Status())
+	})
+	if pprofEnabled {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	klog.InfoS("Serve metrics.", "address", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		klog.Fatalf("Failed to serve metrics: %s", err)
+	}
+}
+
+// serveWebhook serves the conversion webhook of the CRD and the validating
+// webhook of MySQL objects over TLS.
+func serveWebhook(addr, certFile, keyFile string) {
+	mux := http.NewServeMux()
+	mux.Handle(conversion.Path, conversion.Handler())
+	mux.Handle(admission.Path, admission.Handler())
+	klog.InfoS("Serve webhooks.", "address", addr)
+	if err := http.ListenAndServeTLS(addr, certFile, keyFile, mux); err != nil {
+		klog.Fatalf("Failed to serve webhooks: %s", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Package admission serves the validating webhook of MySQL objects. It
+// rejects the updates the controller cannot roll out, such as changes to the
+// fields the data on the volumes depends on.
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+	"github.com/cyhw/mysql-operator/pkg/validation"
+)
+
+// Path is the path the webhook is served on.
+const Path = "/validate"
+
+// Handler serves the AdmissionReviews of the API server.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in admissionv1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Request == nil {
+			http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+			return
+		}
+
+		out := admissionv1.AdmissionReview{
+			TypeMeta: in.TypeMeta,
+			Response: review(in.Request),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			klog.ErrorS(err, "Failed to write AdmissionReview")
+		}
+	})
+}
+
+// review allows the request unless it updates a MySQL in a way
+// validation.ValidateUpdate rejects.
+func review(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: true,
+	}
+	if request.Operation != admissionv1.Update {
+		return response
+	}
+	deny := func(code int32, message string) *admissionv1.AdmissionResponse {
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: message,
+		}
+		return response
+	}
+
+	var obj, old mysqlalpha1.MySQL
+	if err := json.Unmarshal(request.Object.Raw, &obj); err != nil {
+		return deny(http.StatusBadRequest, err.Error())
+	}
+	if err := json.Unmarshal(request.OldObject.Raw, &old); err != nil {
+		return deny(http.StatusBadRequest, err.Error())
+	}
+	errs := validation.ValidateUpdate(&old, &obj)
+	if len(errs) == 0 {
+		return response
+	}
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return deny(http.StatusUnprocessableEntity, strings.Join(messages, ", "))
+}
@@ -0,0 +1,87 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+func newMySQL(t *testing.T, mutate func(*mysqlalpha1.MySQL)) runtime.RawExtension {
+	t.Helper()
+	mysqlObj := &mysqlalpha1.MySQL{
+		TypeMeta:   metav1.TypeMeta{APIVersion: mysqlalpha1.SchemeGroupVersion.String(), Kind: "MySQL"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "db"},
+	}
+	mysqlObj.Spec.Version = "8.0"
+	mutate(mysqlObj)
+	raw, err := json.Marshal(mysqlObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestHandler(t *testing.T) {
+	fast := "fast"
+	for _, tc := range []struct {
+		name      string
+		operation admissionv1.Operation
+		mutate    func(*mysqlalpha1.MySQL)
+		denied    []string
+	}{
+		{"create", admissionv1.Create, func(m *mysqlalpha1.MySQL) { m.Spec.Flavor = mysqlalpha1.FlavorMariaDB }, nil},
+		{"mutable field", admissionv1.Update, func(m *mysqlalpha1.MySQL) { m.Spec.Version = "8.4" }, nil},
+		{"defaults spelled out", admissionv1.Update, func(m *mysqlalpha1.MySQL) {
+			m.Spec.Flavor = mysqlalpha1.FlavorMySQL
+			m.Spec.DataDir = "/var/lib/mysql"
+		}, nil},
+		{"flavor", admissionv1.Update, func(m *mysqlalpha1.MySQL) { m.Spec.Flavor = mysqlalpha1.FlavorMariaDB }, []string{"spec.flavor"}},
+		{"storage class and data dir", admissionv1.Update, func(m *mysqlalpha1.MySQL) {
+			m.Spec.Storage.StorageClassName = &fast
+			m.Spec.DataDir = "/data"
+		}, []string{"spec.dataDir", "spec.storage.storageClassName"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in := admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       "uid",
+					Operation: tc.operation,
+					Object:    newMySQL(t, tc.mutate),
+					OldObject: newMySQL(t, func(*mysqlalpha1.MySQL) {}),
+				},
+			}
+			body, err := json.Marshal(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := httptest.NewRecorder()
+			Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, Path, bytes.NewReader(body)))
+
+			var out admissionv1.AdmissionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &out); err != nil {
+				t.Fatalf("response %q: %v", recorder.Body.String(), err)
+			}
+			if out.Kind != "AdmissionReview" || out.Response == nil || out.Response.UID != "uid" {
+				t.Fatalf("response = %+v", out)
+			}
+			if out.Response.Allowed != (len(tc.denied) == 0) {
+				t.Fatalf("allowed = %v, want %v: %+v", out.Response.Allowed, len(tc.denied) == 0, out.Response.Result)
+			}
+			for _, field := range tc.denied {
+				if !strings.Contains(out.Response.Result.Message, field+" is immutable") {
+					t.Errorf("message %q does not reject %s", out.Response.Result.Message, field)
+				}
+			}
+		})
+	}
+}
@@ -30,7 +30,7 @@ var (
 
 // Adds the list of known types to Scheme.
 func addKnownTypes(scheme *runtime.Scheme) error {
-	scheme.AddKnownTypes(SchemeGroupVersion, &MySQL{}, &MySQLList{})
+	scheme.AddKnownTypes(SchemeGroupVersion, &MySQL{}, &MySQLList{}, &MySQLRestore{}, &MySQLRestoreList{})
 
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 
@@ -1,6 +1,9 @@
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -18,14 +21,557 @@ type MySQL struct {
 
 // MySQLSpec is the spec of Mysql.
 type MySQLSpec struct {
-	Version string `json:"version"`
+	// Flavor is the MySQL-compatible server run, mysql, mariadb or percona.
+	// It picks the default image repository and the tools and variables of
+	// the image. Defaults to mysql and cannot be changed once set.
+	Flavor Flavor `json:"flavor,omitempty"`
+
+	// Version tags the image of the flavor repository. Required unless Image
+	// is set, in which case it is ignored.
+	Version string `json:"version,omitempty"`
+
+	// AutoMinorUpgrade lets the controller move Version to the latest version
+	// of the same major made available by the operator, once the MySQL is
+	// available. Each upgrade is recorded as an Event.
+	AutoMinorUpgrade bool `json:"autoMinorUpgrade,omitempty"`
+
+	// AllowDowngrade lets Version go below the running version. MySQL does
+	// not support downgrades in place, so they are refused by default.
+	AllowDowngrade bool `json:"allowDowngrade,omitempty"`
+
+	// Image is the full reference of the MySQL image, used verbatim in place
+	// of the flavor repository tagged with Version, e.g. to pull from a
+	// private registry or pin a digest. Changing it rolls the pods.
+	Image string `json:"image,omitempty"`
+
+	// Replicas is the number of MySQL pods. Defaults to 1. Zero scales the
+	// MySQL down while keeping its data.
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Suspend scales the MySQL down to zero pods while keeping its data, and
+	// back to Replicas once cleared.
+	Suspend bool `json:"suspend,omitempty"`
+
+	// PodManagementPolicy is the pod management policy of the StatefulSet,
+	// OrderedReady or Parallel. Defaults to OrderedReady and cannot be
+	// changed once set.
+	PodManagementPolicy appsv1.PodManagementPolicyType `json:"podManagementPolicy,omitempty"`
+
+	// MinReadySeconds is how long an updated pod must be ready before the
+	// rollout moves to the next one, so pods crashing right after becoming
+	// ready stop it. Defaults to 0.
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+
+	// RevisionHistoryLimit is the number of ControllerRevisions the
+	// StatefulSet keeps to roll back to. Defaults to 10.
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// ShutdownTimeout is how long a stopping pod waits for MySQL to shut
+	// down cleanly, which the pod termination grace period is derived from.
+	// Pods get 10 seconds to stop when unset. Changing it rolls the pods.
+	ShutdownTimeout *metav1.Duration `json:"shutdownTimeout,omitempty"`
+
+	// UpgradeStrategy controls how a new image is rolled out. Defaults to
+	// RollingUpdate. Switching to BlueGreen rolls the pods once, and takes
+	// effect from the following upgrade.
+	UpgradeStrategy UpgradeStrategy `json:"upgradeStrategy,omitempty"`
+
+	Upgrade MySQLUpgradeSpec `json:"upgrade,omitempty"`
+
+	// PodLabels are added to the pods only. They must not use the keys of
+	// the pod selector.
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+
+	// PodAnnotations are added to the pods only. Changing them rolls the
+	// pods.
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+
+	// PVCLabels and PVCAnnotations are added to the volume claims, e.g. for
+	// the selectors of backup and snapshot tools. They are immutable, like
+	// the claim templates of the StatefulSet. PVCLabels must not use the
+	// keys of the pod selector.
+	PVCLabels      map[string]string `json:"pvcLabels,omitempty"`
+	PVCAnnotations map[string]string `json:"pvcAnnotations,omitempty"`
+
+	// ConfigMapName names a ConfigMap of my.cnf files mounted in
+	// /etc/mysql/conf.d. Changing its content rolls the pods.
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// StartupScript is a shell script run once, when the data directory is
+	// initialized, after the init files of the image. Changing it does not
+	// affect initialized instances.
+	StartupScript string `json:"startupScript,omitempty"`
+
+	// ReadOnly starts the server read-only, including for the users with
+	// the SUPER privilege where the flavor supports it, e.g. to freeze the
+	// database during a migration. The data directory is still initialized
+	// and the startup script run on a new server. Changing it rolls the
+	// pods. It wraps the entrypoint of the image, which an image set by
+	// Image must provide at the path of its flavor.
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// PriorityClassName is the priority class applied to the MySQL pods.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// RuntimeClassName is the runtime class of the MySQL pods, e.g. to run
+	// them in a sandboxed runtime such as gVisor or Kata.
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// PodOverhead is the overhead of the MySQL pods accounted by the
+	// scheduler on top of their containers. It is normally left unset and
+	// filled in from the RuntimeClass, which rejects pods setting another
+	// one.
+	PodOverhead corev1.ResourceList `json:"podOverhead,omitempty"`
+
+	// SchedulerName is the scheduler placing the MySQL pods, such as volcano
+	// for gang scheduling. Empty uses the default scheduler.
+	SchedulerName string `json:"schedulerName,omitempty"`
+
+	// GangScheduling creates a Volcano PodGroup so the pods of a MySQL with
+	// more than one replica are scheduled all together or not at all. It
+	// takes effect when Volcano is installed and SchedulerName is volcano,
+	// and requires the Parallel PodManagementPolicy: with OrderedReady the
+	// StatefulSet creates one pod at a time and the gang is never complete.
+	GangScheduling bool `json:"gangScheduling,omitempty"`
+
+	// TopologySpreadConstraints are applied to the MySQL pods. When empty and
+	// more than one replica is requested, pods are spread across zones.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// HostAliases are added to the /etc/hosts file of the MySQL pods, e.g.
+	// for replication sources outside the cluster DNS.
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
+	// ReadinessGates are conditions the MySQL pods must also meet to be ready,
+	// e.g. one set by a sidecar once replication caught up.
+	ReadinessGates []corev1.PodReadinessGate `json:"readinessGates,omitempty"`
+
+	// Sidecars are added to the MySQL pods, e.g. log shippers or proxies.
+	// Their names must not collide with the mysql and exporter containers.
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+
+	// ExtraSecretMounts mounts Secrets read-only in the mysql container, e.g.
+	// credentials read by plugins or init scripts. Their paths must be
+	// distinct and must not overlap the data, binlog, config, init script
+	// and audit log directories.
+	ExtraSecretMounts []MySQLSecretMount `json:"extraSecretMounts,omitempty"`
+
+	// DeletionPolicy controls what happens to the data when the MySQL is
+	// deleted. Defaults to Delete.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// PVCRetentionPolicy lets the StatefulSet controller delete the data
+	// claims when the StatefulSet is deleted or scaled down. Unset retains
+	// them. Requires Kubernetes 1.27 or the StatefulSetAutoDeletePVC feature.
+	PVCRetentionPolicy *appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy `json:"pvcRetentionPolicy,omitempty"`
+
+	Backup MySQLBackupSpec `json:"backup,omitempty"`
+
+	// Config holds server settings rendered into a my.cnf file mounted in
+	// /etc/mysql/conf.d. Changing it rolls the pods.
+	Config MySQLConfigSpec `json:"config,omitempty"`
+
+	// CharacterSet is the default character set of the server. Defaults to
+	// utf8mb4.
+	CharacterSet string `json:"characterSet,omitempty"`
+
+	// Collation is the default collation of the server. Defaults to
+	// utf8mb4_unicode_ci.
+	Collation string `json:"collation,omitempty"`
+
+	// Database is the name of a database created on first start.
+	Database string `json:"database,omitempty"`
+
+	// ConnectionSecret makes the controller maintain a <name>-connection
+	// Secret with the host, port, username, password and database keys.
+	ConnectionSecret bool `json:"connectionSecret,omitempty"`
+
+	Storage MySQLStorageSpec `json:"storage,omitempty"`
+
+	// DataDir is the absolute path the data volume is mounted on, passed to
+	// mysqld as its datadir. Defaults to /var/lib/mysql; images following
+	// other conventions may need another one. It cannot be changed once set.
+	DataDir string `json:"dataDir,omitempty"`
+
+	// DataSubPath is the relative path within the data volume mounted on
+	// DataDir, e.g. to leave out the lost+found directory of a filesystem
+	// or share the volume. It cannot be changed once set, as the pods would
+	// start on another directory.
+	DataSubPath string `json:"dataSubPath,omitempty"`
+
+	// Port is the port MySQL listens on and the Services expose. Defaults to
+	// 3306. Changing it rolls the pods.
+	Port int32 `json:"port,omitempty"`
+
+	// Ephemeral keeps the data in emptyDir volumes instead of PVCs, e.g. for
+	// test databases. It excludes Storage and BinlogStorage and cannot be
+	// changed once set.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+
+	// BinlogStorage puts the binary logs on a separate volume of the given
+	// size. Binary logs stay on the data volume when unset.
+	BinlogStorage *MySQLStorageSpec `json:"binlogStorage,omitempty"`
+
+	Service MySQLServiceSpec `json:"service,omitempty"`
+
+	// AdditionalServicePorts are exposed by the client Service next to the
+	// MySQL port, e.g. the X Protocol. Their names and ports must be unique.
+	// The network policy allows them from the same sources as MySQL.
+	AdditionalServicePorts []corev1.ServicePort `json:"additionalServicePorts,omitempty"`
+
+	Metrics MySQLMetricsSpec `json:"metrics,omitempty"`
+
+	Probes MySQLProbesSpec `json:"probes,omitempty"`
+
+	// NetworkPolicy restricts the ingress to the MySQL pods to the given
+	// sources. All ingress is allowed when unset.
+	NetworkPolicy *MySQLNetworkPolicySpec `json:"networkPolicy,omitempty"`
+
+	Proxy MySQLProxySpec `json:"proxy,omitempty"`
+
+	XProtocol MySQLXProtocolSpec `json:"xProtocol,omitempty"`
+
+	AuditLog MySQLAuditLogSpec `json:"auditLog,omitempty"`
+}
+
+// MySQLAuditLogSpec configures the audit log plugin of the server.
+type MySQLAuditLogSpec struct {
+	// Enabled loads the audit log plugin, audit_log for the mysql and
+	// percona flavors and server_audit for mariadb, writing to the
+	// mysql-audit volume mounted on /var/log/mysql-audit. Sidecars may
+	// mount the volume to ship the log. The pods do not start if the image
+	// lacks the plugin, which MySQL Community does not ship.
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// MySQLXProtocolSpec configures the X Protocol of the Document Store.
+type MySQLXProtocolSpec struct {
+	// Enabled serves the X Protocol on port 33060 of the pods and the client
+	// Service. It is not supported by the mariadb flavor, nor by the proxy.
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// MySQLConfigSpec holds the settings of the my.cnf file of the servers.
+type MySQLConfigSpec struct {
+	// MaxConnections is max_connections.
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
+
+	// InnodbBufferPoolSize is innodb_buffer_pool_size.
+	InnodbBufferPoolSize *resource.Quantity `json:"innodbBufferPoolSize,omitempty"`
+
+	// MaxAllowedPacket is max_allowed_packet.
+	MaxAllowedPacket *resource.Quantity `json:"maxAllowedPacket,omitempty"`
+
+	// Options are further settings keyed by section.key, e.g.
+	// mysqld.slow_query_log. Keys without a section are in mysqld. They take
+	// precedence over the fields above.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// MySQLMetricsSpec configures the export of the server metrics to Prometheus.
+type MySQLMetricsSpec struct {
+	// Enabled adds a mysqld-exporter sidecar to the pods, served by the
+	// <name>-metrics Service.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// ServiceMonitor makes the controller maintain a Prometheus Operator
+	// ServiceMonitor scraping the exporter. It requires Enabled.
+	ServiceMonitor bool `json:"serviceMonitor,omitempty"`
+
+	// Relabelings and MetricRelabelings are set on the endpoint of the
+	// ServiceMonitor, e.g. to drop labels of high cardinality.
+	Relabelings       []MySQLRelabelConfig `json:"relabelings,omitempty"`
+	MetricRelabelings []MySQLRelabelConfig `json:"metricRelabelings,omitempty"`
+}
+
+// MySQLRelabelConfig is a Prometheus relabeling rule, as in the
+// RelabelConfig of the Prometheus Operator.
+type MySQLRelabelConfig struct {
+	SourceLabels []string `json:"sourceLabels,omitempty"`
+	Separator    string   `json:"separator,omitempty"`
+	TargetLabel  string   `json:"targetLabel,omitempty"`
+	Regex        string   `json:"regex,omitempty"`
+	Modulus      int64    `json:"modulus,omitempty"`
+	Replacement  *string  `json:"replacement,omitempty"`
+	Action       string   `json:"action,omitempty"`
+}
+
+// MySQLNetworkPolicySpec lists the sources allowed to connect to the servers,
+// besides the pods of the instance, its Jobs and the proxy.
+type MySQLNetworkPolicySpec struct {
+	// AllowedNamespaces are the namespaces whose pods may connect.
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// AllowedPodSelectors select the pods of the MySQL namespace that may
+	// connect.
+	AllowedPodSelectors []metav1.LabelSelector `json:"allowedPodSelectors,omitempty"`
+}
+
+// MySQLProxySpec configures a ProxySQL Deployment routing the client
+// connections to the MySQL.
+type MySQLProxySpec struct {
+	// Enabled deploys ProxySQL behind the <name>-proxy Service, which then
+	// becomes the connection endpoint. Clients connect through it as the
+	// proxysql user, whose password is in the password key of the
+	// <name>-proxy Secret.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Replicas is the number of ProxySQL pods. Defaults to 1.
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Image overrides the ProxySQL image.
+	Image string `json:"image,omitempty"`
+}
+
+// MySQLProbesSpec tunes the probes of the mysql container.
+type MySQLProbesSpec struct {
+	// Liveness defaults to a Ping every 10s, with a 5s timeout and 3
+	// failures.
+	Liveness MySQLProbeSpec `json:"liveness,omitempty"`
+
+	// Readiness defaults to a Query every 5s, with a 5s timeout and 3
+	// failures.
+	Readiness MySQLProbeSpec `json:"readiness,omitempty"`
+
+	// Startup defaults to a Ping every 10s, with a 5s timeout and 30
+	// failures, leaving five minutes for MySQL to initialize or recover.
+	Startup MySQLProbeSpec `json:"startup,omitempty"`
+}
+
+// MySQLProbeSpec tunes a probe. Zero values are defaulted.
+type MySQLProbeSpec struct {
+	Mode                ProbeMode `json:"mode,omitempty"`
+	InitialDelaySeconds int32     `json:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int32     `json:"periodSeconds,omitempty"`
+	TimeoutSeconds      int32     `json:"timeoutSeconds,omitempty"`
+	FailureThreshold    int32     `json:"failureThreshold,omitempty"`
+}
+
+// ProbeMode is how a probe checks the server.
+type ProbeMode string
+
+const (
+	// ProbeModePing only checks that the server answers, which it also does
+	// while it cannot serve queries yet.
+	ProbeModePing ProbeMode = "Ping"
+	// ProbeModeQuery runs SELECT 1.
+	ProbeModeQuery ProbeMode = "Query"
+)
+
+// MySQLServiceSpec customizes the Service of Mysql.
+type MySQLServiceSpec struct {
+	// Annotations are added to the client Service, e.g. to configure cloud
+	// load balancers. Annotations managed by the controller take precedence.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// MySQLSecretMount mounts a Secret at a path of the mysql container.
+type MySQLSecretMount struct {
+	SecretName string `json:"secretName"`
+	MountPath  string `json:"mountPath"`
+}
+
+// MySQLStorageSpec is the data volume size of Mysql. When only one of request
+// and limit is set, it is used for both.
+type MySQLStorageSpec struct {
+	Request *resource.Quantity `json:"request,omitempty"`
+	Limit   *resource.Quantity `json:"limit,omitempty"`
+
+	// StorageClassName is the storage class of the volumes, the default class
+	// of the cluster when unset. It cannot be changed once set, as the data
+	// would have to move to new volumes.
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+// DeletionPolicy describes how the data of a deleted MySQL is handled.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete removes the MySQL together with its data.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain leaves the data volumes behind.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicySnapshot runs a final backup before the MySQL is removed.
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
+// Flavor is a MySQL-compatible server.
+type Flavor string
+
+const (
+	FlavorMySQL   Flavor = "mysql"
+	FlavorMariaDB Flavor = "mariadb"
+	FlavorPercona Flavor = "percona"
+)
+
+// UpgradeStrategy describes how a MySQL is moved to a new image.
+type UpgradeStrategy string
+
+const (
+	// UpgradeStrategyRollingUpdate replaces the pods one at a time.
+	UpgradeStrategyRollingUpdate UpgradeStrategy = "RollingUpdate"
+	// UpgradeStrategyBlueGreen provisions a second StatefulSet on the new
+	// image replicating from the current one, and switches the Service over
+	// once it has caught up. Replication is set up with the statements of the
+	// new image: CHANGE MASTER TO and START SLAVE for MariaDB and versions
+	// before 8.0.26, CHANGE REPLICATION SOURCE TO and START REPLICA from
+	// 8.0.26 on, the only ones 8.4 accepts.
+	UpgradeStrategyBlueGreen UpgradeStrategy = "BlueGreen"
+)
+
+// MySQLUpgradeSpec tunes the move of a MySQL to a new image.
+type MySQLUpgradeSpec struct {
+	// BackupFirst dumps the databases to the backup claim before the new
+	// image is rolled out, and holds the upgrade until the dump succeeds.
+	// It requires spec.backup.claimName.
+	BackupFirst bool `json:"backupFirst,omitempty"`
+}
+
+// MySQLBackupSpec is the backup configuration of Mysql.
+type MySQLBackupSpec struct {
+	// ClaimName is the PersistentVolumeClaim the backup dumps are written to.
+	ClaimName string `json:"claimName,omitempty"`
+
+	// Schedule is the cron schedule of the periodic backups.
+	Schedule string `json:"schedule,omitempty"`
+
+	// SnapshotClassName enables VolumeSnapshots of the data volume on every
+	// scheduled backup, using the given VolumeSnapshotClass.
+	SnapshotClassName string `json:"snapshotClassName,omitempty"`
+
+	HistoryLimit MySQLBackupHistoryLimit `json:"historyLimit,omitempty"`
+}
+
+// MySQLBackupHistoryLimit is the number of finished backup Jobs kept.
+type MySQLBackupHistoryLimit struct {
+	// Successful defaults to 3.
+	Successful *int32 `json:"successful,omitempty"`
+
+	// Failed defaults to 1.
+	Failed *int32 `json:"failed,omitempty"`
 }
 
 // MySQLStatus is the status of Mysql.
 type MySQLStatus struct {
 	Message string `json:"message"`
+
+	// ConnectionEndpoint is the in-cluster address clients connect to. It is
+	// set once the MySQL is ready.
+	ConnectionEndpoint string `json:"connectionEndpoint,omitempty"`
+
+	// ReadOnly is true once all the pods run read-only.
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// AuditLog is true once all the pods run with the audit log plugin.
+	AuditLog bool `json:"auditLog,omitempty"`
+
+	// RunningImage is the image the mysql containers run, resolved to its
+	// digest. It is only updated while all pods run the same image.
+	RunningImage string `json:"runningImage,omitempty"`
+
+	// LastSnapshotName is the name of the latest VolumeSnapshot taken.
+	LastSnapshotName string `json:"lastSnapshotName,omitempty"`
+
+	// UpgradeBackup is the file on the backup claim holding the dump taken
+	// before the last upgrade.
+	UpgradeBackup string `json:"upgradeBackup,omitempty"`
+
+	// Restore is the MySQLRestore in progress. The MySQL is held at zero pods
+	// until its dump is loaded.
+	Restore string `json:"restore,omitempty"`
+
+	// OnDemandBackup is the state of the last backup requested through the
+	// volc.bytedance.com/backup-request annotation.
+	OnDemandBackup *MySQLOnDemandBackupStatus `json:"onDemandBackup,omitempty"`
+
+	// ObservedGeneration is the generation of the spec last reconciled.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Replicas is the replication state of the pods replicating from a
+	// source, as reported by their exporter sidecar.
+	Replicas []MySQLReplicaStatus `json:"replicas,omitempty"`
+
+	// MaxReplicationLagSeconds is the largest lag among Replicas.
+	MaxReplicationLagSeconds *int64 `json:"maxReplicationLagSeconds,omitempty"`
+
+	// Resources are the objects managed for the MySQL.
+	Resources []MySQLResourceStatus `json:"resources,omitempty"`
+
+	// BlueGreen tracks the StatefulSets of the BlueGreen upgrade strategy.
+	BlueGreen *MySQLBlueGreenStatus `json:"blueGreen,omitempty"`
+
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// MySQLOnDemandBackupStatus is the state of a backup run outside the
+// schedule.
+type MySQLOnDemandBackupStatus struct {
+	// Request is the value of the annotation that requested the backup.
+	Request string `json:"request"`
+
+	// JobName is the Job running the backup.
+	JobName string `json:"jobName"`
+
+	Phase BackupPhase `json:"phase"`
+
+	// CompletionTime is when the Job succeeded or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
+// BackupPhase is the progress of an on-demand backup.
+type BackupPhase string
+
+const (
+	BackupPhaseRunning   BackupPhase = "Running"
+	BackupPhaseSucceeded BackupPhase = "Succeeded"
+	BackupPhaseFailed    BackupPhase = "Failed"
+)
+
+// MySQLReplicaStatus is the replication state of a pod.
+type MySQLReplicaStatus struct {
+	// Name is the name of the pod.
+	Name string `json:"name"`
+
+	// LagSeconds is how far the pod is behind its source.
+	LagSeconds int64 `json:"lagSeconds"`
+}
+
+// MySQLResourceStatus is the state of an object managed for a MySQL.
+type MySQLResourceStatus struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+
+	// Ready is true once the object exists and, for workloads, all its
+	// replicas are ready.
+	Ready bool `json:"ready"`
+}
+
+// MySQLBlueGreenStatus names the StatefulSets of a blue/green upgrade. The
+// controller derives it from the roles annotated on the StatefulSets.
+type MySQLBlueGreenStatus struct {
+	// Active is the StatefulSet serving the MySQL.
+	Active string `json:"active"`
+
+	// Candidate is the StatefulSet provisioned on the new image while an
+	// upgrade is in progress.
+	Candidate string `json:"candidate,omitempty"`
+}
+
+const (
+	// ConditionDegraded is True when the MySQL is not working as expected,
+	// with the reason explaining why.
+	ConditionDegraded = "Degraded"
+	// ConditionSuspended is True while the MySQL is scaled down by
+	// spec.suspend.
+	ConditionSuspended = "Suspended"
+	// ConditionAvailable is True once all replicas are ready and, for
+	// replicas, replication keeps up.
+	ConditionAvailable = "Available"
+	// ConditionProgressing is True while the pods are moved to a new spec,
+	// including the backup taken before an upgrade.
+	ConditionProgressing = "Progressing"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // MySQLList is the list of Mysql resources.
@@ -35,3 +581,67 @@ type MySQLList struct {
 
 	Items []MySQL `json:"items"`
 }
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MySQLRestore loads a dump into the data volumes of a MySQL, which is scaled
+// down meanwhile and back up once done.
+type MySQLRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MySQLRestoreSpec   `json:"spec"`
+	Status MySQLRestoreStatus `json:"status,omitempty"`
+}
+
+// MySQLRestoreSpec is the spec of MySQLRestore.
+type MySQLRestoreSpec struct {
+	// MySQLName is the MySQL of the namespace the dump is loaded into.
+	MySQLName string `json:"mysqlName"`
+
+	// ClaimName is the PersistentVolumeClaim holding the dump. Defaults to
+	// spec.backup.claimName of the MySQL.
+	ClaimName string `json:"claimName,omitempty"`
+
+	// File is the path of the dump on the claim, such as a file written by a
+	// backup or status.upgradeBackup.
+	File string `json:"file"`
+}
+
+// MySQLRestoreStatus is the progress of a MySQLRestore.
+type MySQLRestoreStatus struct {
+	Phase RestorePhase `json:"phase,omitempty"`
+
+	// Message explains a failure.
+	Message string `json:"message,omitempty"`
+
+	// StartTime is when the MySQL started scaling down.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the restore succeeded or failed.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// RestorePhase is the progress of a MySQLRestore.
+type RestorePhase string
+
+const (
+	// RestorePhasePending waits for an earlier restore of the same MySQL.
+	RestorePhasePending     RestorePhase = "Pending"
+	RestorePhaseScalingDown RestorePhase = "ScalingDown"
+	RestorePhaseRestoring   RestorePhase = "Restoring"
+	RestorePhaseScalingUp   RestorePhase = "ScalingUp"
+	RestorePhaseSucceeded   RestorePhase = "Succeeded"
+	RestorePhaseFailed      RestorePhase = "Failed"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MySQLRestoreList is the list of MySQLRestore resources.
+type MySQLRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []MySQLRestore `json:"items"`
+}
@@ -22,6 +22,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -30,8 +33,8 @@ func (in *MySQL) DeepCopyInto(out *MySQL) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -53,6 +56,119 @@ func (in *MySQL) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLAuditLogSpec) DeepCopyInto(out *MySQLAuditLogSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLAuditLogSpec.
+func (in *MySQLAuditLogSpec) DeepCopy() *MySQLAuditLogSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLAuditLogSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLBackupHistoryLimit) DeepCopyInto(out *MySQLBackupHistoryLimit) {
+	*out = *in
+	if in.Successful != nil {
+		in, out := &in.Successful, &out.Successful
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Failed != nil {
+		in, out := &in.Failed, &out.Failed
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLBackupHistoryLimit.
+func (in *MySQLBackupHistoryLimit) DeepCopy() *MySQLBackupHistoryLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLBackupHistoryLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLBackupSpec) DeepCopyInto(out *MySQLBackupSpec) {
+	*out = *in
+	in.HistoryLimit.DeepCopyInto(&out.HistoryLimit)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLBackupSpec.
+func (in *MySQLBackupSpec) DeepCopy() *MySQLBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLBlueGreenStatus) DeepCopyInto(out *MySQLBlueGreenStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLBlueGreenStatus.
+func (in *MySQLBlueGreenStatus) DeepCopy() *MySQLBlueGreenStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLBlueGreenStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLConfigSpec) DeepCopyInto(out *MySQLConfigSpec) {
+	*out = *in
+	if in.MaxConnections != nil {
+		in, out := &in.MaxConnections, &out.MaxConnections
+		*out = new(int32)
+		**out = **in
+	}
+	if in.InnodbBufferPoolSize != nil {
+		in, out := &in.InnodbBufferPoolSize, &out.InnodbBufferPoolSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.MaxAllowedPacket != nil {
+		in, out := &in.MaxAllowedPacket, &out.MaxAllowedPacket
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLConfigSpec.
+func (in *MySQLConfigSpec) DeepCopy() *MySQLConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MySQLList) DeepCopyInto(out *MySQLList) {
 	*out = *in
@@ -86,9 +202,459 @@ func (in *MySQLList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLMetricsSpec) DeepCopyInto(out *MySQLMetricsSpec) {
+	*out = *in
+	if in.Relabelings != nil {
+		in, out := &in.Relabelings, &out.Relabelings
+		*out = make([]MySQLRelabelConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MetricRelabelings != nil {
+		in, out := &in.MetricRelabelings, &out.MetricRelabelings
+		*out = make([]MySQLRelabelConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLMetricsSpec.
+func (in *MySQLMetricsSpec) DeepCopy() *MySQLMetricsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLMetricsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLNetworkPolicySpec) DeepCopyInto(out *MySQLNetworkPolicySpec) {
+	*out = *in
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedPodSelectors != nil {
+		in, out := &in.AllowedPodSelectors, &out.AllowedPodSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLNetworkPolicySpec.
+func (in *MySQLNetworkPolicySpec) DeepCopy() *MySQLNetworkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLNetworkPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLOnDemandBackupStatus) DeepCopyInto(out *MySQLOnDemandBackupStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLOnDemandBackupStatus.
+func (in *MySQLOnDemandBackupStatus) DeepCopy() *MySQLOnDemandBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLOnDemandBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLProbeSpec) DeepCopyInto(out *MySQLProbeSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLProbeSpec.
+func (in *MySQLProbeSpec) DeepCopy() *MySQLProbeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLProbeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLProbesSpec) DeepCopyInto(out *MySQLProbesSpec) {
+	*out = *in
+	out.Liveness = in.Liveness
+	out.Readiness = in.Readiness
+	out.Startup = in.Startup
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLProbesSpec.
+func (in *MySQLProbesSpec) DeepCopy() *MySQLProbesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLProbesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLProxySpec) DeepCopyInto(out *MySQLProxySpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLProxySpec.
+func (in *MySQLProxySpec) DeepCopy() *MySQLProxySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLProxySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLRelabelConfig) DeepCopyInto(out *MySQLRelabelConfig) {
+	*out = *in
+	if in.SourceLabels != nil {
+		in, out := &in.SourceLabels, &out.SourceLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Replacement != nil {
+		in, out := &in.Replacement, &out.Replacement
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLRelabelConfig.
+func (in *MySQLRelabelConfig) DeepCopy() *MySQLRelabelConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLRelabelConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLReplicaStatus) DeepCopyInto(out *MySQLReplicaStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLReplicaStatus.
+func (in *MySQLReplicaStatus) DeepCopy() *MySQLReplicaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLReplicaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLResourceStatus) DeepCopyInto(out *MySQLResourceStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLResourceStatus.
+func (in *MySQLResourceStatus) DeepCopy() *MySQLResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLRestore) DeepCopyInto(out *MySQLRestore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLRestore.
+func (in *MySQLRestore) DeepCopy() *MySQLRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MySQLRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLRestoreList) DeepCopyInto(out *MySQLRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MySQLRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLRestoreList.
+func (in *MySQLRestoreList) DeepCopy() *MySQLRestoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLRestoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MySQLRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLRestoreSpec) DeepCopyInto(out *MySQLRestoreSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLRestoreSpec.
+func (in *MySQLRestoreSpec) DeepCopy() *MySQLRestoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLRestoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLRestoreStatus) DeepCopyInto(out *MySQLRestoreStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLRestoreStatus.
+func (in *MySQLRestoreStatus) DeepCopy() *MySQLRestoreStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLSecretMount) DeepCopyInto(out *MySQLSecretMount) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLSecretMount.
+func (in *MySQLSecretMount) DeepCopy() *MySQLSecretMount {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLSecretMount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLServiceSpec) DeepCopyInto(out *MySQLServiceSpec) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLServiceSpec.
+func (in *MySQLServiceSpec) DeepCopy() *MySQLServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MySQLSpec) DeepCopyInto(out *MySQLSpec) {
 	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ShutdownTimeout != nil {
+		in, out := &in.ShutdownTimeout, &out.ShutdownTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	out.Upgrade = in.Upgrade
+	if in.PodLabels != nil {
+		in, out := &in.PodLabels, &out.PodLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PodAnnotations != nil {
+		in, out := &in.PodAnnotations, &out.PodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PVCLabels != nil {
+		in, out := &in.PVCLabels, &out.PVCLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PVCAnnotations != nil {
+		in, out := &in.PVCAnnotations, &out.PVCAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RuntimeClassName != nil {
+		in, out := &in.RuntimeClassName, &out.RuntimeClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.PodOverhead != nil {
+		in, out := &in.PodOverhead, &out.PodOverhead
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]v1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReadinessGates != nil {
+		in, out := &in.ReadinessGates, &out.ReadinessGates
+		*out = make([]v1.PodReadinessGate, len(*in))
+		copy(*out, *in)
+	}
+	if in.Sidecars != nil {
+		in, out := &in.Sidecars, &out.Sidecars
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraSecretMounts != nil {
+		in, out := &in.ExtraSecretMounts, &out.ExtraSecretMounts
+		*out = make([]MySQLSecretMount, len(*in))
+		copy(*out, *in)
+	}
+	if in.PVCRetentionPolicy != nil {
+		in, out := &in.PVCRetentionPolicy, &out.PVCRetentionPolicy
+		*out = new(appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy)
+		**out = **in
+	}
+	in.Backup.DeepCopyInto(&out.Backup)
+	in.Config.DeepCopyInto(&out.Config)
+	in.Storage.DeepCopyInto(&out.Storage)
+	if in.BinlogStorage != nil {
+		in, out := &in.BinlogStorage, &out.BinlogStorage
+		*out = new(MySQLStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Service.DeepCopyInto(&out.Service)
+	if in.AdditionalServicePorts != nil {
+		in, out := &in.AdditionalServicePorts, &out.AdditionalServicePorts
+		*out = make([]v1.ServicePort, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Metrics.DeepCopyInto(&out.Metrics)
+	out.Probes = in.Probes
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(MySQLNetworkPolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Proxy.DeepCopyInto(&out.Proxy)
+	out.XProtocol = in.XProtocol
+	out.AuditLog = in.AuditLog
 	return
 }
 
@@ -102,9 +668,72 @@ func (in *MySQLSpec) DeepCopy() *MySQLSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLStorageSpec) DeepCopyInto(out *MySQLStorageSpec) {
+	*out = *in
+	if in.Request != nil {
+		in, out := &in.Request, &out.Request
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLStorageSpec.
+func (in *MySQLStorageSpec) DeepCopy() *MySQLStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MySQLStatus) DeepCopyInto(out *MySQLStatus) {
 	*out = *in
+	if in.OnDemandBackup != nil {
+		in, out := &in.OnDemandBackup, &out.OnDemandBackup
+		*out = new(MySQLOnDemandBackupStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = make([]MySQLReplicaStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxReplicationLagSeconds != nil {
+		in, out := &in.MaxReplicationLagSeconds, &out.MaxReplicationLagSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]MySQLResourceStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.BlueGreen != nil {
+		in, out := &in.BlueGreen, &out.BlueGreen
+		*out = new(MySQLBlueGreenStatus)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -117,3 +746,35 @@ func (in *MySQLStatus) DeepCopy() *MySQLStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLUpgradeSpec) DeepCopyInto(out *MySQLUpgradeSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLUpgradeSpec.
+func (in *MySQLUpgradeSpec) DeepCopy() *MySQLUpgradeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLUpgradeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MySQLXProtocolSpec) DeepCopyInto(out *MySQLXProtocolSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MySQLXProtocolSpec.
+func (in *MySQLXProtocolSpec) DeepCopy() *MySQLXProtocolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MySQLXProtocolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
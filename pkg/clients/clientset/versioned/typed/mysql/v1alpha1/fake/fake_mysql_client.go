@@ -32,6 +32,10 @@ func (c *FakeVolcV1alpha1) MySQLs(namespace string) v1alpha1.MySQLInterface {
 	return &FakeMySQLs{c, namespace}
 }
 
+func (c *FakeVolcV1alpha1) MySQLRestores(namespace string) v1alpha1.MySQLRestoreInterface {
+	return &FakeMySQLRestores{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeVolcV1alpha1) RESTClient() rest.Interface {
@@ -29,6 +29,7 @@ import (
 type VolcV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	MySQLsGetter
+	MySQLRestoresGetter
 }
 
 // VolcV1alpha1Client is used to interact with features provided by the volc.bytedance.com group.
@@ -40,6 +41,10 @@ func (c *VolcV1alpha1Client) MySQLs(namespace string) MySQLInterface {
 	return newMySQLs(c, namespace)
 }
 
+func (c *VolcV1alpha1Client) MySQLRestores(namespace string) MySQLRestoreInterface {
+	return newMySQLRestores(c, namespace)
+}
+
 // NewForConfig creates a new VolcV1alpha1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
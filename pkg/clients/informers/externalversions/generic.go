@@ -55,6 +55,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=volc.bytedance.com, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithResource("mysqls"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Volc().V1alpha1().MySQLs().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("mysqlrestores"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Volc().V1alpha1().MySQLRestores().Informer()}, nil
 
 	}
 
@@ -26,6 +26,8 @@ import (
 type Interface interface {
 	// MySQLs returns a MySQLInformer.
 	MySQLs() MySQLInformer
+	// MySQLRestores returns a MySQLRestoreInformer.
+	MySQLRestores() MySQLRestoreInformer
 }
 
 type version struct {
@@ -43,3 +45,8 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) MySQLs() MySQLInformer {
 	return &mySQLInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// MySQLRestores returns a MySQLRestoreInformer.
+func (v *version) MySQLRestores() MySQLRestoreInformer {
+	return &mySQLRestoreInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
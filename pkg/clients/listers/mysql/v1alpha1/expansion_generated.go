@@ -25,3 +25,11 @@ type MySQLListerExpansion interface{}
 // MySQLNamespaceListerExpansion allows custom methods to be added to
 // MySQLNamespaceLister.
 type MySQLNamespaceListerExpansion interface{}
+
+// MySQLRestoreListerExpansion allows custom methods to be added to
+// MySQLRestoreLister.
+type MySQLRestoreListerExpansion interface{}
+
+// MySQLRestoreNamespaceListerExpansion allows custom methods to be added to
+// MySQLRestoreNamespaceLister.
+type MySQLRestoreNamespaceListerExpansion interface{}
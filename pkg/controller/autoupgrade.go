@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	reasonAutoMinorUpgrade = "AutoMinorUpgrade"
+	autoUpgradeInterval    = time.Hour
+)
+
+// autoMinorUpgrade moves spec.version to the latest available version of the
+// same major, and reports whether it did. The versions are read from the
+// ConfigMap named by Options.VersionsConfigMap, one key per flavor listing
+// versions separated by whitespace. The upgrade itself is left to the
+// reconcile of the new spec, so it follows the upgrade strategy and
+// spec.upgrade.backupFirst.
+func (c *Controller) autoMinorUpgrade(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	if !mysqlObj.Spec.AutoMinorUpgrade || c.options.VersionsConfigMap == "" {
+		return false, nil
+	}
+	// Checked again later, as the list of versions may change.
+	c.enqueueAfter(mysqlObj, autoUpgradeInterval)
+
+	// The image overrides the version, and an instance that is not serving
+	// or already rolling is not upgraded.
+	if mysqlObj.Spec.Image != "" || mysqlObj.Generation != mysqlObj.Status.ObservedGeneration ||
+		!meta.IsStatusConditionTrue(mysqlObj.Status.Conditions, mysqlalpha1.ConditionAvailable) ||
+		meta.IsStatusConditionTrue(mysqlObj.Status.Conditions, mysqlalpha1.ConditionProgressing) {
+		return false, nil
+	}
+
+	versions, err := c.availableVersions(mysqlObj)
+	if err != nil {
+		return false, err
+	}
+	target := latestMinor(mysqlObj.Spec.Version, versions)
+	if target == "" {
+		return false, nil
+	}
+
+	patch := fmt.Sprintf(`{"spec":{"version":%q}}`, target)
+	_, err = c.crClient.VolcV1alpha1().MySQLs(mysqlObj.Namespace).Patch(context.TODO(), mysqlObj.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return false, err
+	}
+	klog.InfoS("Upgrade minor version.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "from", mysqlObj.Spec.Version, "to", target)
+	c.recorder.Eventf(mysqlObj, corev1.EventTypeNormal, reasonAutoMinorUpgrade, "Upgrading from version %s to %s", mysqlObj.Spec.Version, target)
+	return true, nil
+}
+
+// availableVersions returns the versions listed for the flavor of the MySQL.
+// A missing ConfigMap lists none.
+func (c *Controller) availableVersions(mysqlObj *mysqlalpha1.MySQL) ([]string, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(c.options.VersionsConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	configMap, err := c.configMapLister.ConfigMaps(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	flavor := mysqlObj.Spec.Flavor
+	if flavor == "" {
+		flavor = mysqlalpha1.FlavorMySQL
+	}
+	return strings.Fields(configMap.Data[string(flavor)]), nil
+}
+
+// latestMinor returns the highest of the versions above the current one with
+// the same major, or an empty string. Versions that do not parse are skipped.
+func latestMinor(current string, versions []string) string {
+	currentVersion, err := utilversion.ParseGeneric(current)
+	if err != nil {
+		return ""
+	}
+	var latest *utilversion.Version
+	var ret string
+	for _, v := range versions {
+		version, err := utilversion.ParseGeneric(v)
+		if err != nil || version.Major() != currentVersion.Major() || !currentVersion.LessThan(version) {
+			continue
+		}
+		if latest == nil || latest.LessThan(version) {
+			latest, ret = version, v
+		}
+	}
+	return ret
+}
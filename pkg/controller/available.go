@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	reasonAvailable           = "Available"
+	reasonStatefulSetNotFound = "StatefulSetNotFound"
+	reasonNotReady            = "NotReady"
+	reasonReplicationLag      = "ReplicationLag"
+)
+
+// readinessCheck is a gate the MySQL must pass to be Available.
+type readinessCheck interface {
+	// check returns the reason and message of the failure, or an empty
+	// reason when the gate passes.
+	check(mysqlObj *mysqlalpha1.MySQL, sts *v1.StatefulSet, pods []*corev1.Pod) (string, string, error)
+}
+
+// syncAvailable sets the Available condition once every readiness check
+// passes.
+func (c *Controller) syncAvailable(mysqlObj *mysqlalpha1.MySQL) error {
+	sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(statefulSetName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		setAvailable(mysqlObj, reasonStatefulSetNotFound, "The StatefulSet does not exist")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if desiredReplicas(mysqlObj) == 0 {
+		setAvailable(mysqlObj, reasonScaledDown, "Scaled down to zero replicas")
+		return nil
+	}
+
+	pods, err := c.listPods(sts)
+	if err != nil {
+		return err
+	}
+	for _, check := range c.readinessChecks(mysqlObj) {
+		reason, message, err := check.check(mysqlObj, sts, pods)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			setAvailable(mysqlObj, reason, message)
+			return nil
+		}
+	}
+	setAvailable(mysqlObj, "", "")
+	return nil
+}
+
+// readinessChecks returns the gates of the MySQL.
+func (c *Controller) readinessChecks(mysqlObj *mysqlalpha1.MySQL) []readinessCheck {
+	checks := []readinessCheck{
+		statefulSetReadiness{},
+	}
+	if replicated(mysqlObj) {
+		checks = append(checks, replicationReadiness{c.options.MaxReplicationLag})
+	}
+	return checks
+}
+
+// statefulSetReadiness passes once all desired replicas of the current
+// revision are ready.
+type statefulSetReadiness struct{}
+
+func (statefulSetReadiness) check(mysqlObj *mysqlalpha1.MySQL, sts *v1.StatefulSet, _ []*corev1.Pod) (string, string, error) {
+	desired := desiredReplicas(mysqlObj)
+	if sts.Status.ObservedGeneration < sts.Generation || sts.Status.ReadyReplicas < desired {
+		return reasonNotReady, fmt.Sprintf("%d of %d replicas ready", sts.Status.ReadyReplicas, desired), nil
+	}
+	return "", "", nil
+}
+
+// replicationReadiness passes while no replica lags too far behind its
+// source, as recorded in the status by syncStatus.
+type replicationReadiness struct {
+	maxLag time.Duration
+}
+
+func (r replicationReadiness) check(mysqlObj *mysqlalpha1.MySQL, _ *v1.StatefulSet, _ []*corev1.Pod) (string, string, error) {
+	reason, message := replicationLagged(mysqlObj, r.maxLag)
+	return reason, message, nil
+}
+
+// setAvailable sets the Available condition, which is True when reason is
+// empty.
+func setAvailable(mysqlObj *mysqlalpha1.MySQL, reason, message string) {
+	cond := metav1.Condition{
+		Type:               mysqlalpha1.ConditionAvailable,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: mysqlObj.Generation,
+		Reason:             reason,
+		Message:            message,
+	}
+	if reason == "" {
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = reasonAvailable
+	}
+	meta.SetStatusCondition(&mysqlObj.Status.Conditions, cond)
+}
@@ -0,0 +1,366 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	backupContainerName        = "backup"
+	backupVolumeName           = "mysql-backup"
+	backupMountPath            = "/backup"
+	backupBackoffLimit         = int32(2)
+	volumeSnapshotGroupVersion = schema.GroupVersion{Group: "snapshot.storage.k8s.io", Version: "v1"}
+	volumeSnapshotResource     = volumeSnapshotGroupVersion.WithResource("volumesnapshots")
+	// apiResourceTTL is how long the discovery of an optional resource is
+	// trusted, so installing its CRD is noticed without a restart.
+	apiResourceTTL = 5 * time.Minute
+)
+
+// apiResource is a cached discovery result.
+type apiResource struct {
+	available bool
+	expires   time.Time
+}
+
+// syncBackup converges the backup CronJob of a MySQL.
+func (c *Controller) syncBackup(mysqlObj *mysqlalpha1.MySQL) error {
+	name := backupCronJobName(mysqlObj)
+	cronJob, err := c.cronJobLister.CronJobs(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		cronJob = nil
+	} else if err != nil {
+		return err
+	}
+
+	if mysqlObj.Spec.Backup.Schedule == "" {
+		if cronJob == nil {
+			return nil
+		}
+		err = c.k8sClient.BatchV1().CronJobs(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete backup cronjob.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+
+	desired := newBackupCronJob(mysqlObj)
+	if cronJob == nil {
+		_, err = c.k8sClient.BatchV1().CronJobs(mysqlObj.Namespace).Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create backup cronjob.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if cronJob.Annotations[specHashAnnotation] != desired.Annotations[specHashAnnotation] {
+		ret := cronJob.DeepCopy()
+		ret.Annotations = desired.Annotations
+		ret.Spec = desired.Spec
+		_, err = c.k8sClient.BatchV1().CronJobs(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Update backup cronjob.", "namespace", mysqlObj.Namespace, "name", name)
+	}
+	return nil
+}
+
+// syncSnapshot takes a VolumeSnapshot for every backup the CronJob has
+// completed, recording it in the status.
+func (c *Controller) syncSnapshot(mysqlObj *mysqlalpha1.MySQL) error {
+	if mysqlObj.Spec.Backup.Schedule == "" || mysqlObj.Spec.Backup.SnapshotClassName == "" {
+		return nil
+	}
+	cronJob, err := c.cronJobLister.CronJobs(mysqlObj.Namespace).Get(backupCronJobName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cronJob.Status.LastSuccessfulTime == nil {
+		return nil
+	}
+	return c.snapshot(mysqlObj, cronJob.Status.LastSuccessfulTime)
+}
+
+// backupUpToDate reports whether the backup CronJob matches the spec.
+func (c *Controller) backupUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	cronJob, err := c.cronJobLister.CronJobs(mysqlObj.Namespace).Get(backupCronJobName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		return mysqlObj.Spec.Backup.Schedule == "", nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if mysqlObj.Spec.Backup.Schedule == "" {
+		return false, nil
+	}
+	return cronJob.Annotations[specHashAnnotation] == newBackupCronJob(mysqlObj).Annotations[specHashAnnotation], nil
+}
+
+// snapshot takes a VolumeSnapshot of the data volume for the backup finished
+// at the given time, unless it was already taken.
+func (c *Controller) snapshot(mysqlObj *mysqlalpha1.MySQL, finished *metav1.Time) error {
+	name := fmt.Sprintf("%s-%s", mysqlObj.Name, finished.UTC().Format("20060102150405"))
+	if mysqlObj.Status.LastSnapshotName == name {
+		return nil
+	}
+
+	available, err := c.resourceAvailable(volumeSnapshotResource)
+	if err != nil {
+		return err
+	}
+	if !available {
+		klog.InfoS("VolumeSnapshot is not available, skip snapshot.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name)
+		return nil
+	}
+
+	_, err = c.dynamicClient.Resource(volumeSnapshotResource).Namespace(mysqlObj.Namespace).Create(context.Background(), newVolumeSnapshot(mysqlObj, name), metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	klog.InfoS("Create volume snapshot.", "namespace", mysqlObj.Namespace, "name", name)
+
+	mysqlObj.Status.LastSnapshotName = name
+	return nil
+}
+
+// resourceAvailable reports whether the API server serves the resource, such
+// as the ones of optional CRDs. The answer is cached for apiResourceTTL.
+func (c *Controller) resourceAvailable(resource schema.GroupVersionResource) (bool, error) {
+	if cached, ok := c.apiResources.Load(resource); ok && time.Now().Before(cached.(apiResource).expires) {
+		return cached.(apiResource).available, nil
+	}
+	available, err := c.discoverResource(resource)
+	if err != nil {
+		return false, err
+	}
+	c.apiResources.Store(resource, apiResource{available: available, expires: time.Now().Add(apiResourceTTL)})
+	return available, nil
+}
+
+func (c *Controller) discoverResource(resource schema.GroupVersionResource) (bool, error) {
+	resources, err := c.k8sClient.Discovery().ServerResourcesForGroupVersion(resource.GroupVersion().String())
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == resource.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// syncBackupJobs prunes the finished on-demand backup Jobs and the ones left
+// behind by a CronJob that was deleted or replaced, keeping as many as the
+// history limits. The Jobs of the current CronJob are pruned by the CronJob
+// controller.
+func (c *Controller) syncBackupJobs(mysqlObj *mysqlalpha1.MySQL) error {
+	jobs, err := c.jobLister.Jobs(mysqlObj.Namespace).List(SelectorForInstance(mysqlObj.Name))
+	if err != nil {
+		return err
+	}
+	cronJob, err := c.cronJobLister.CronJobs(mysqlObj.Namespace).Get(backupCronJobName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		cronJob = nil
+	} else if err != nil {
+		return err
+	}
+
+	var succeeded, failed []*batchv1.Job
+	for _, job := range jobs {
+		if ownerRef := metav1.GetControllerOf(job); cronJob != nil && ownerRef != nil && ownerRef.UID == cronJob.UID {
+			continue
+		}
+		// The other Jobs of the instance, such as the one creating the
+		// proxy user, are not backups.
+		if job.Labels[jobKindLabelKey] != "" {
+			continue
+		}
+		switch jobFinished(job) {
+		case batchv1.JobComplete:
+			succeeded = append(succeeded, job)
+		case batchv1.JobFailed:
+			failed = append(failed, job)
+		}
+	}
+	if err := c.pruneJobs(succeeded, *mysqlObj.Spec.Backup.HistoryLimit.Successful); err != nil {
+		return err
+	}
+	return c.pruneJobs(failed, *mysqlObj.Spec.Backup.HistoryLimit.Failed)
+}
+
+// pruneJobs deletes the oldest Jobs beyond limit.
+func (c *Controller) pruneJobs(jobs []*batchv1.Job, limit int32) error {
+	if int32(len(jobs)) <= limit {
+		return nil
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreationTimestamp.Before(&jobs[j].CreationTimestamp)
+	})
+	propagation := metav1.DeletePropagationBackground
+	for _, job := range jobs[:int32(len(jobs))-limit] {
+		err := c.k8sClient.BatchV1().Jobs(job.Namespace).Delete(context.Background(), job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete stale backup job.", "namespace", job.Namespace, "name", job.Name)
+	}
+	return nil
+}
+
+// jobFinished returns JobComplete or JobFailed once the Job has finished, or
+// an empty condition type.
+func jobFinished(job *batchv1.Job) batchv1.JobConditionType {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status == corev1.ConditionTrue && (cond.Type == batchv1.JobComplete || cond.Type == batchv1.JobFailed) {
+			return cond.Type
+		}
+	}
+	return ""
+}
+
+// jobPodLabels are set on the pods of the Jobs run against a MySQL, so that its
+// NetworkPolicy admits them. They do not match the MySQL pods.
+func jobPodLabels(mysqlObj *mysqlalpha1.MySQL) map[string]string {
+	return map[string]string{
+		jobLabelKey: mysqlObj.Name,
+	}
+}
+
+// backupJobLabels are set on the Jobs of the backup CronJob.
+func backupJobLabels(mysqlObj *mysqlalpha1.MySQL) map[string]string {
+	return LabelsForInstance(mysqlObj.Name)
+}
+
+func backupCronJobName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-backup"
+}
+
+// backupCommand returns the command of the backup Jobs.
+func backupCommand(mysqlObj *mysqlalpha1.MySQL) string {
+	// Without a claim to dump into, the job only flushes the tables so the
+	// following snapshot is consistent.
+	f := flavorOf(mysqlObj)
+	if mysqlObj.Spec.Backup.ClaimName == "" {
+		return fmt.Sprintf("%s -h %s%s -uroot -p\"$%s\" -e 'FLUSH TABLES'",
+			f.client, clientServiceHost(mysqlObj), portArg(mysqlObj), f.passwordEnv)
+	}
+	return fmt.Sprintf("%s -h %s%s -uroot -p\"$%s\" --all-databases > %s/%s-$(date +%%Y%%m%%d%%H%%M%%S).sql",
+		f.dump, clientServiceHost(mysqlObj), portArg(mysqlObj), f.passwordEnv, backupMountPath, mysqlObj.Name)
+}
+
+func newBackupCronJob(mysqlObj *mysqlalpha1.MySQL) *batchv1.CronJob {
+	spec := batchv1.CronJobSpec{
+		Schedule:                   mysqlObj.Spec.Backup.Schedule,
+		ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+		SuccessfulJobsHistoryLimit: mysqlObj.Spec.Backup.HistoryLimit.Successful,
+		FailedJobsHistoryLimit:     mysqlObj.Spec.Backup.HistoryLimit.Failed,
+		JobTemplate: batchv1.JobTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: backupJobLabels(mysqlObj),
+			},
+			Spec: newBackupJobSpec(mysqlObj, backupCommand(mysqlObj)),
+		},
+	}
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupCronJobName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+			Annotations: map[string]string{
+				specHashAnnotation: hashObject(spec),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: spec,
+	}
+}
+
+func newBackupJobSpec(mysqlObj *mysqlalpha1.MySQL, command string) batchv1.JobSpec {
+	spec := batchv1.JobSpec{
+		BackoffLimit: &backupBackoffLimit,
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: jobPodLabels(mysqlObj),
+			},
+			Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyNever,
+				Containers: []corev1.Container{
+					{
+						Name:    backupContainerName,
+						Image:   mysqlImage(mysqlObj),
+						Command: []string{"sh", "-c", command},
+						Env: []corev1.EnvVar{
+							rootPasswordEnv(mysqlObj),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if mysqlObj.Spec.Backup.ClaimName != "" {
+		spec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+			{
+				Name:      backupVolumeName,
+				MountPath: backupMountPath,
+			},
+		}
+		spec.Template.Spec.Volumes = []corev1.Volume{
+			{
+				Name: backupVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: mysqlObj.Spec.Backup.ClaimName,
+					},
+				},
+			},
+		}
+	}
+	return spec
+}
+
+func newVolumeSnapshot(mysqlObj *mysqlalpha1.MySQL, name string) *unstructured.Unstructured {
+	labels := map[string]interface{}{}
+	for k, v := range LabelsForInstance(mysqlObj.Name) {
+		labels[k] = v
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": volumeSnapshotGroupVersion.String(),
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": mysqlObj.Namespace,
+				"labels":    labels,
+			},
+			"spec": map[string]interface{}{
+				"volumeSnapshotClassName": mysqlObj.Spec.Backup.SnapshotClassName,
+				"source": map[string]interface{}{
+					"persistentVolumeClaimName": volumeMountName + "-" + statefulSetName(mysqlObj) + "-0",
+				},
+			},
+		},
+	}
+}
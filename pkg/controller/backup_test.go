@@ -0,0 +1,67 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/cyhw/mysql-operator/pkg/controller"
+	ctrltesting "github.com/cyhw/mysql-operator/pkg/controller/testing"
+)
+
+func completeJob(job *batchv1.Job) {
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   batchv1.JobComplete,
+		Status: corev1.ConditionTrue,
+	})
+}
+
+func TestReconcilePrunesBackupJobs(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	mysqlObj.Spec.Proxy.Enabled = true
+	keep := int32(1)
+	mysqlObj.Spec.Backup.HistoryLimit.Successful = &keep
+	var kubeObjects []runtime.Object
+	for i := 0; i < 3; i++ {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("db-backup-%d", i),
+				Namespace:         "ns",
+				Labels:            controller.LabelsForInstance("db"),
+				CreationTimestamp: metav1.Unix(int64(i), 0),
+			},
+		}
+		completeJob(job)
+		kubeObjects = append(kubeObjects, job)
+	}
+	f := ctrltesting.NewFixture(kubeObjects, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	if deleted := f.Deleted("jobs"); len(deleted) != 2 {
+		t.Errorf("deleted jobs %v, want the two oldest backups", deleted)
+	}
+
+	// The finished proxy user Job is not a backup and is kept.
+	jobs := f.K8sClient.BatchV1().Jobs("ns")
+	job, err := jobs.Get(context.Background(), "db-proxy-user", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("proxy user job: %v", err)
+	}
+	completeJob(job)
+	if _, err := jobs.Update(context.Background(), job, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.K8sClient.ClearActions()
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	if deleted := f.Deleted("jobs"); len(deleted) != 0 {
+		t.Errorf("deleted jobs %v, want none", deleted)
+	}
+}
@@ -0,0 +1,530 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	statefulSetLabelKey  = "volc.bytedance.com/statefulset"
+	seedContainerName    = "seed"
+	seedVolumeName       = "mysql-seed"
+	seedMountPath        = "/docker-entrypoint-initdb.d"
+	promoteContainerName = "promote"
+	// blueGreenRoleAnnotation records the role of a StatefulSet in the
+	// blue/green upgrades. It is written before anything is deleted or
+	// switched, so the sets are told apart even when the status is lost.
+	blueGreenRoleAnnotation = "volc.bytedance.com/blue-green-role"
+	blueGreenRoleActive     = "active"
+	blueGreenRoleCandidate  = "candidate"
+	blueGreenRoleRetired    = "retired"
+)
+
+// statefulSetName returns the name of the StatefulSet serving the MySQL.
+func statefulSetName(mysqlObj *mysqlalpha1.MySQL) string {
+	if mysqlObj.Status.BlueGreen != nil && mysqlObj.Status.BlueGreen.Active != "" {
+		return mysqlObj.Status.BlueGreen.Active
+	}
+	return mysqlObj.Name + "-deployment"
+}
+
+// peerStatefulSetName returns the name the blue/green upgrades alternate with.
+func peerStatefulSetName(mysqlObj *mysqlalpha1.MySQL, name string) string {
+	if name == mysqlObj.Name+"-deployment" {
+		return name + "-green"
+	}
+	return mysqlObj.Name + "-deployment"
+}
+
+// statefulSetNames returns the names of both StatefulSets a MySQL may run.
+func statefulSetNames(mysqlObj *mysqlalpha1.MySQL) []string {
+	name := mysqlObj.Name + "-deployment"
+	return []string{name, peerStatefulSetName(mysqlObj, name)}
+}
+
+// candidateStatefulSetName returns the StatefulSet provisioned by the upgrade
+// in progress, if any.
+func candidateStatefulSetName(mysqlObj *mysqlalpha1.MySQL) string {
+	if mysqlObj.Status.BlueGreen == nil {
+		return ""
+	}
+	return mysqlObj.Status.BlueGreen.Candidate
+}
+
+// observeBlueGreen fills the blue/green status from the roles recorded on the
+// StatefulSets of the MySQL. The persisted status is only used when no set
+// carries a role, such as the sets of an older release.
+func (c *Controller) observeBlueGreen(mysqlObj *mysqlalpha1.MySQL) error {
+	var active, candidate string
+	for _, name := range statefulSetNames(mysqlObj) {
+		sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(name)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if ownerRef := metav1.GetControllerOf(sts); ownerRef == nil || ownerRef.UID != mysqlObj.UID {
+			continue
+		}
+		switch sts.Annotations[blueGreenRoleAnnotation] {
+		case blueGreenRoleActive:
+			active = name
+		case blueGreenRoleCandidate:
+			candidate = name
+		}
+	}
+
+	switch {
+	case candidate != "":
+		mysqlObj.Status.BlueGreen = &mysqlalpha1.MySQLBlueGreenStatus{
+			Active:    peerStatefulSetName(mysqlObj, candidate),
+			Candidate: candidate,
+		}
+	case active != "":
+		mysqlObj.Status.BlueGreen = &mysqlalpha1.MySQLBlueGreenStatus{Active: active}
+	}
+	return nil
+}
+
+func blueGreen(mysqlObj *mysqlalpha1.MySQL) bool {
+	return mysqlObj.Spec.UpgradeStrategy == mysqlalpha1.UpgradeStrategyBlueGreen
+}
+
+// syncBlueGreen moves the MySQL to a new image by provisioning a candidate
+// StatefulSet next to the active one. The candidate seeds its data from the
+// active set and replicates from it; once it is ready and caught up, the
+// Service is switched over and the candidate is promoted. The roles of both
+// sets are recorded before the retired set is deleted as an orphan; its claims
+// are kept until the next upgrade reuses its name. It reports false when the
+// StatefulSet is to be updated in place instead.
+func (c *Controller) syncBlueGreen(mysqlObj *mysqlalpha1.MySQL, active *v1.StatefulSet, checksum string) (bool, error) {
+	// The upgrade keeps its progress in the status, the StatefulSets are
+	// left as they are.
+	if c.options.DisableStatusUpdates && (blueGreen(mysqlObj) || mysqlObj.Status.BlueGreen != nil) {
+		klog.V(2).InfoS("Skip blue/green upgrade without status updates.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name)
+		return true, nil
+	}
+	candidate := candidateStatefulSetName(mysqlObj)
+	if candidate == "" {
+		if !blueGreen(mysqlObj) {
+			if mysqlObj.Status.BlueGreen == nil {
+				return false, nil
+			}
+			return false, c.selectStatefulSet(mysqlObj, "")
+		}
+		// Pods created before the strategy was set lack the label the
+		// Service is switched with.
+		if desiredReplicas(mysqlObj) == 0 || containerImage(active) == mysqlImage(mysqlObj) ||
+			active.Spec.Template.Labels[statefulSetLabelKey] != active.Name {
+			return false, c.selectRetiring(mysqlObj, active)
+		}
+		// A promote Job left by an interrupted upgrade would pass the new
+		// candidate as promoted.
+		if err := c.deletePromoteJob(mysqlObj); err != nil {
+			return true, err
+		}
+		candidate = peerStatefulSetName(mysqlObj, active.Name)
+		mysqlObj.Status.BlueGreen = &mysqlalpha1.MySQLBlueGreenStatus{Active: active.Name, Candidate: candidate}
+		klog.InfoS("Start blue/green upgrade.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "candidate", candidate)
+	}
+
+	promoteJob, err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Get(context.Background(), promoteJobName(mysqlObj), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		promoteJob = nil
+	} else if err != nil {
+		return true, err
+	}
+	if promoteJob == nil {
+		// Clients stay on the active set until the candidate takes over.
+		if err := c.selectStatefulSet(mysqlObj, active.Name); err != nil {
+			return true, err
+		}
+		ready, err := c.syncCandidate(mysqlObj, candidate, checksum)
+		if err != nil || !ready {
+			return true, err
+		}
+	}
+
+	if err := c.selectStatefulSet(mysqlObj, candidate); err != nil {
+		return true, err
+	}
+	done, err := c.promote(mysqlObj, promoteJob, candidate)
+	if err != nil || !done {
+		return true, err
+	}
+	// The active set is retired first: until the candidate is marked
+	// active, the next reconcile resumes the promotion.
+	if err := c.setBlueGreenRole(active, blueGreenRoleRetired); err != nil {
+		return true, err
+	}
+	sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(candidate)
+	if err != nil {
+		return true, err
+	}
+	if err := c.setBlueGreenRole(sts, blueGreenRoleActive); err != nil {
+		return true, err
+	}
+	if err := c.deletePromoteJob(mysqlObj); err != nil {
+		return true, err
+	}
+	mysqlObj.Status.BlueGreen = &mysqlalpha1.MySQLBlueGreenStatus{Active: candidate}
+	klog.InfoS("Finish blue/green upgrade.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "active", candidate)
+	return true, nil
+}
+
+// setBlueGreenRole records the role of the StatefulSet.
+func (c *Controller) setBlueGreenRole(sts *v1.StatefulSet, role string) error {
+	if sts.Annotations[blueGreenRoleAnnotation] == role {
+		return nil
+	}
+	ret := sts.DeepCopy()
+	if ret.Annotations == nil {
+		ret.Annotations = map[string]string{}
+	}
+	ret.Annotations[blueGreenRoleAnnotation] = role
+	_, err := c.k8sClient.AppsV1().StatefulSets(ret.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Set blue/green role.", "namespace", ret.Namespace, "name", ret.Name, "role", role)
+	return nil
+}
+
+func (c *Controller) deletePromoteJob(mysqlObj *mysqlalpha1.MySQL) error {
+	propagation := metav1.DeletePropagationBackground
+	err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Delete(context.Background(), promoteJobName(mysqlObj), metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// syncCandidate converges the candidate StatefulSet and reports whether it is
+// ready to take over.
+func (c *Controller) syncCandidate(mysqlObj *mysqlalpha1.MySQL, name, checksum string) (bool, error) {
+	desired := newStatefulSet(mysqlObj, name, checksum)
+	sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		// The claims of a set retired by a previous upgrade hold stale data,
+		// which would keep the candidate from seeding.
+		claims, err := c.listClaims(mysqlObj)
+		if err != nil {
+			return false, err
+		}
+		for _, claim := range claims {
+			if claim.DeletionTimestamp != nil || !isClaimOfStatefulSet(claim.Name, name) {
+				continue
+			}
+			err = c.k8sClient.CoreV1().PersistentVolumeClaims(claim.Namespace).Delete(context.Background(), claim.Name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return false, err
+			}
+			klog.InfoS("Delete stale PVC.", "namespace", claim.Namespace, "name", claim.Name)
+		}
+		desired.Annotations[blueGreenRoleAnnotation] = blueGreenRoleCandidate
+		return false, c.createStatefulSet(mysqlObj, desired)
+	}
+	if err != nil {
+		return false, err
+	}
+	if sts.Annotations[specHashAnnotation] != desired.Annotations[specHashAnnotation] {
+		ret := sts.DeepCopy()
+		if ret.Annotations == nil {
+			ret.Annotations = map[string]string{}
+		}
+		ret.Annotations[specHashAnnotation] = desired.Annotations[specHashAnnotation]
+		ret.Spec.Replicas = desired.Spec.Replicas
+		ret.Spec.MinReadySeconds = desired.Spec.MinReadySeconds
+		ret.Spec.RevisionHistoryLimit = desired.Spec.RevisionHistoryLimit
+		ret.Spec.PersistentVolumeClaimRetentionPolicy = desired.Spec.PersistentVolumeClaimRetentionPolicy
+		ret.Spec.Template = desired.Spec.Template
+		_, err = c.k8sClient.AppsV1().StatefulSets(ret.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+		if err != nil {
+			return false, err
+		}
+		klog.InfoS("Update candidate statefulset.", "namespace", ret.Namespace, "name", ret.Name)
+		return false, nil
+	}
+
+	if reason, _, _ := (statefulSetReadiness{}).check(mysqlObj, sts, nil); reason != "" {
+		return false, nil
+	}
+	if !mysqlObj.Spec.Metrics.Enabled {
+		return true, nil
+	}
+	// The lag is only observable through the exporter sidecar.
+	pods, err := c.listPods(sts)
+	if err != nil {
+		return false, err
+	}
+	for _, pod := range pods {
+		lag, _, err := c.replicationLag(pod)
+		if err != nil || lag > 0 {
+			klog.InfoS("Wait for candidate to catch up.", "namespace", pod.Namespace, "name", pod.Name, "lag", lag)
+			c.enqueueAfter(mysqlObj, statefulSetPollInterval)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// promote stops the replication of the candidate pods from the retired set
+// and reports whether it has completed.
+func (c *Controller) promote(mysqlObj *mysqlalpha1.MySQL, job *batchv1.Job, candidate string) (bool, error) {
+	name := promoteJobName(mysqlObj)
+	if job == nil {
+		_, err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Create(context.Background(), newPromoteJob(mysqlObj, candidate), metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		klog.InfoS("Create promote job.", "namespace", mysqlObj.Namespace, "name", name)
+		c.enqueueAfter(mysqlObj, statefulSetPollInterval)
+		return false, nil
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			return false, fmt.Errorf("promote job %s failed: %s", name, cond.Message)
+		}
+	}
+	c.enqueueAfter(mysqlObj, statefulSetPollInterval)
+	return false, nil
+}
+
+// selectRetiring keeps the Service on the active set while a retired one
+// still runs.
+func (c *Controller) selectRetiring(mysqlObj *mysqlalpha1.MySQL, active *v1.StatefulSet) error {
+	_, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(peerStatefulSetName(mysqlObj, active.Name))
+	if apierrors.IsNotFound(err) {
+		return c.selectStatefulSet(mysqlObj, "")
+	}
+	if err != nil {
+		return err
+	}
+	return c.selectStatefulSet(mysqlObj, active.Name)
+}
+
+// selectStatefulSet restricts the client Service to the pods of the named
+// StatefulSet, or to the pods of any set when name is empty.
+func (c *Controller) selectStatefulSet(mysqlObj *mysqlalpha1.MySQL, name string) error {
+	current, err := c.serviceLister.Services(mysqlObj.Namespace).Get(clientServiceName(mysqlObj))
+	if err != nil {
+		return err
+	}
+	selector := clientSelector(mysqlObj, name)
+	if equality.Semantic.DeepEqual(current.Spec.Selector, selector) {
+		return nil
+	}
+
+	service := current.DeepCopy()
+	service.Spec.Selector = selector
+	_, err = c.k8sClient.CoreV1().Services(service.Namespace).Update(context.Background(), service, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Switch service.", "namespace", service.Namespace, "name", service.Name, "statefulset", name)
+	return nil
+}
+
+// blueGreenPodTemplate prepares the pods of a set for blue/green upgrades:
+// they carry the label the Service is switched with, write binary logs to
+// replicate from, and seed an empty data directory from the peer set.
+func blueGreenPodTemplate(mysqlObj *mysqlalpha1.MySQL, name string, template *corev1.PodTemplateSpec) {
+	template.Labels[statefulSetLabelKey] = name
+
+	// Every pod of the two sets needs its own server id, derived from the
+	// set and the ordinal at the end of the hostname of the pod. The ids of
+	// the second set start above any ordinal of the first.
+	base := 1
+	if name != mysqlObj.Name+"-deployment" {
+		base = 1 << 31
+	}
+	serverID := fmt.Sprintf(`set -- "$@" --server-id=$((%d + ${HOSTNAME##*-}))`, base)
+	container := &template.Spec.Containers[0]
+	if container.Command == nil {
+		entrypoint := flavorOf(mysqlObj).entrypoint
+		container.Command = []string{"sh", "-c", serverID + "\nexec " + entrypoint + ` "$@"`, entrypoint}
+	} else {
+		// The read-only script passes its arguments on to the entrypoint.
+		container.Command[2] = serverID + "\n" + container.Command[2]
+	}
+	if mysqlObj.Spec.BinlogStorage == nil {
+		container.Args = append(container.Args, "--log-bin=mysql-bin")
+	}
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      seedVolumeName,
+		MountPath: seedMountPath,
+	})
+
+	script := fmt.Sprintf("cat > %s/seed.sh <<'EOF'\n%sEOF\nchmod +x %s/seed.sh",
+		seedMountPath, seedScript(mysqlObj, peerStatefulSetName(mysqlObj, name)), seedMountPath)
+	template.Spec.InitContainers = append(template.Spec.InitContainers, corev1.Container{
+		Name:    seedContainerName,
+		Image:   mysqlImage(mysqlObj),
+		Command: []string{"sh", "-c", script},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      seedVolumeName,
+				MountPath: seedMountPath,
+			},
+		},
+	})
+	template.Spec.Volumes = append(template.Spec.Volumes, newEmptyDirVolume(seedVolumeName))
+}
+
+// replicationSyntax holds the statements and dump option that set up
+// replication. MySQL 8.0.26 renamed them from master and slave to source and
+// replica, and 8.4 removed the old names, which MariaDB keeps.
+type replicationSyntax struct {
+	// change starts the statement setting the source, whose options are
+	// prefixed with option.
+	change string
+	option string
+	start  string
+	stop   string
+	reset  string
+	// dumpData makes the dump set the binlog coordinates of the source.
+	dumpData string
+}
+
+var (
+	masterSyntax = replicationSyntax{
+		change:   "CHANGE MASTER TO",
+		option:   "MASTER",
+		start:    "START SLAVE",
+		stop:     "STOP SLAVE",
+		reset:    "RESET SLAVE ALL",
+		dumpData: "--master-data=1",
+	}
+	sourceSyntax = replicationSyntax{
+		change:   "CHANGE REPLICATION SOURCE TO",
+		option:   "SOURCE",
+		start:    "START REPLICA",
+		stop:     "STOP REPLICA",
+		reset:    "RESET REPLICA ALL",
+		dumpData: "--source-data=1",
+	}
+	sourceSyntaxVersion = utilversion.MustParseGeneric("8.0.26")
+)
+
+// replicationSyntaxOf returns the replication syntax of the image the MySQL
+// runs. MySQL and Percona Server tags that do not parse, such as latest, are
+// taken to be recent; a major.minor tag such as 8.0 to be its first release.
+func replicationSyntaxOf(mysqlObj *mysqlalpha1.MySQL) replicationSyntax {
+	if mysqlObj.Spec.Flavor == mysqlalpha1.FlavorMariaDB {
+		return masterSyntax
+	}
+	version, err := utilversion.ParseGeneric(imageTag(mysqlImage(mysqlObj)))
+	if err == nil && version.LessThan(sourceSyntaxVersion) {
+		return masterSyntax
+	}
+	return sourceSyntax
+}
+
+// seedScript is run by the image entrypoint on an empty data directory. It
+// copies the data of the first pod of the peer set and replicates from it,
+// and does nothing when the peer set is not running. The source is set before
+// the dump is loaded: setting the source host resets the binlog coordinates,
+// which the dump sets afterwards.
+func seedScript(mysqlObj *mysqlalpha1.MySQL, peer string) string {
+	source := fmt.Sprintf("%s-0.%s", peer, serviceHost(mysqlObj))
+	f := flavorOf(mysqlObj)
+	r := replicationSyntaxOf(mysqlObj)
+	lines := []string{
+		"#!/bin/sh",
+		"set -e",
+		fmt.Sprintf("getent hosts %s >/dev/null || exit 0", source),
+		fmt.Sprintf("%s -uroot -p\"$%s\" -e \"%s %s_HOST='%s', %s_PORT=%d, %s_USER='root', %s_PASSWORD='$%s';\"",
+			f.client, f.passwordEnv, r.change, r.option, source, r.option, mysqlPort(mysqlObj), r.option, r.option, f.passwordEnv),
+		fmt.Sprintf("%s -h %s%s -uroot -p\"$%s\" --all-databases --single-transaction %s | %s -uroot -p\"$%s\"",
+			f.dump, source, portArg(mysqlObj), f.passwordEnv, r.dumpData, f.client, f.passwordEnv),
+		fmt.Sprintf("%s -uroot -p\"$%s\" -e \"%s;\"", f.client, f.passwordEnv, r.start),
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func promoteJobName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-promote"
+}
+
+func newPromoteJob(mysqlObj *mysqlalpha1.MySQL, candidate string) *batchv1.Job {
+	f := flavorOf(mysqlObj)
+	r := replicationSyntaxOf(mysqlObj)
+	var commands []string
+	for i := int32(0); i < desiredReplicas(mysqlObj); i++ {
+		commands = append(commands, fmt.Sprintf("%s -h %s-%d.%s%s -uroot -p\"$%s\" -e '%s; %s'",
+			f.client, candidate, i, serviceHost(mysqlObj), portArg(mysqlObj), f.passwordEnv, r.stop, r.reset))
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      promoteJobName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backupBackoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: jobPodLabels(mysqlObj),
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    promoteContainerName,
+							Image:   mysqlImage(mysqlObj),
+							Command: []string{"sh", "-c", strings.Join(commands, " && ")},
+							Env: []corev1.EnvVar{
+								rootPasswordEnv(mysqlObj),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// containerImage returns the image of the mysql container of a StatefulSet.
+func containerImage(sts *v1.StatefulSet) string {
+	for _, container := range sts.Spec.Template.Spec.Containers {
+		if container.Name == containerName {
+			return container.Image
+		}
+	}
+	return ""
+}
+
+// isClaimOfStatefulSet reports whether the named PVC was created for a pod of
+// the named StatefulSet, as opposed to a set whose name it prefixes.
+func isClaimOfStatefulSet(claim, sts string) bool {
+	for _, template := range []string{volumeMountName, binlogVolumeName} {
+		ordinal := strings.TrimPrefix(claim, template+"-"+sts+"-")
+		if ordinal != claim && ordinal != "" && strings.Trim(ordinal, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
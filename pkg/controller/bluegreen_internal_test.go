@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+func TestSeedScriptSetsSourceBeforeDump(t *testing.T) {
+	for _, tc := range []struct {
+		flavor  mysqlalpha1.Flavor
+		version string
+		want    replicationSyntax
+	}{
+		{mysqlalpha1.FlavorMySQL, "5.7", masterSyntax},
+		{mysqlalpha1.FlavorMySQL, "8.0.25", masterSyntax},
+		{mysqlalpha1.FlavorMySQL, "8.0.36", sourceSyntax},
+		{mysqlalpha1.FlavorMySQL, "8.4", sourceSyntax},
+		{mysqlalpha1.FlavorMySQL, "latest", sourceSyntax},
+		{mysqlalpha1.FlavorPercona, "8.4", sourceSyntax},
+		{mysqlalpha1.FlavorMariaDB, "11.4", masterSyntax},
+	} {
+		t.Run(string(tc.flavor)+":"+tc.version, func(t *testing.T) {
+			mysqlObj := &mysqlalpha1.MySQL{}
+			mysqlObj.Namespace, mysqlObj.Name = "ns", "db"
+			mysqlObj.Spec.Flavor, mysqlObj.Spec.Version = tc.flavor, tc.version
+			script := seedScript(withDefaults(mysqlObj), "db-deployment")
+
+			change := strings.Index(script, tc.want.change+" "+tc.want.option+"_HOST=")
+			dump := strings.Index(script, tc.want.dumpData)
+			start := strings.Index(script, tc.want.start)
+			if change < 0 || dump < 0 || start < 0 {
+				t.Fatalf("seed script lacks a step:\n%s", script)
+			}
+			// Setting the source host resets the coordinates set by the dump.
+			if !(change < dump && dump < start) {
+				t.Errorf("seed script sets the source at %d, loads the dump at %d and starts at %d:\n%s",
+					change, dump, start, script)
+			}
+		})
+	}
+}
+
+func TestBlueGreenServerIDs(t *testing.T) {
+	mysqlObj := &mysqlalpha1.MySQL{}
+	mysqlObj.Namespace, mysqlObj.Name = "ns", "db"
+	mysqlObj.Spec.Version = "8.0"
+	mysqlObj.Spec.UpgradeStrategy = mysqlalpha1.UpgradeStrategyBlueGreen
+	for _, readOnly := range []bool{false, true} {
+		mysqlObj.Spec.ReadOnly = readOnly
+		scripts := map[string]string{}
+		for _, name := range statefulSetNames(mysqlObj) {
+			sts := newStatefulSet(withDefaults(mysqlObj), name, "")
+			command := sts.Spec.Template.Spec.Containers[0].Command
+			if len(command) != 4 || !strings.Contains(command[2], `exec docker-entrypoint.sh "$@"`) {
+				t.Fatalf("%s command = %q", name, command)
+			}
+			scripts[name] = command[2]
+		}
+		// Every pod derives its server id from its set and its ordinal.
+		if !strings.Contains(scripts["db-deployment"], `--server-id=$((1 + ${HOSTNAME##*-}))`) ||
+			!strings.Contains(scripts["db-deployment-green"], `--server-id=$((2147483648 + ${HOSTNAME##*-}))`) {
+			t.Errorf("server ids of the sets:\n%s\n%s", scripts["db-deployment"], scripts["db-deployment-green"])
+		}
+		if readOnly != strings.Contains(scripts["db-deployment"], "--read-only") {
+			t.Errorf("read-only %v script:\n%s", readOnly, scripts["db-deployment"])
+		}
+	}
+}
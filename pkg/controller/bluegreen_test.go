@@ -0,0 +1,63 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+	"github.com/cyhw/mysql-operator/pkg/controller"
+	ctrltesting "github.com/cyhw/mysql-operator/pkg/controller/testing"
+)
+
+// TestBlueGreenRolesOutliveStatus checks that a finished upgrade whose status
+// update was lost keeps the promoted set, instead of recreating the retired
+// one from the stale status.
+func TestBlueGreenRolesOutliveStatus(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	mysqlObj.Spec.UpgradeStrategy = mysqlalpha1.UpgradeStrategyBlueGreen
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	// The upgrade promoted db-deployment-green and retired db-deployment,
+	// but the status still names no blue/green set.
+	statefulSets := f.K8sClient.AppsV1().StatefulSets("ns")
+	blue, err := statefulSets.Get(context.Background(), "db-deployment", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	green := blue.DeepCopy()
+	green.ResourceVersion = ""
+	green.Name = "db-deployment-green"
+	green.Annotations["volc.bytedance.com/blue-green-role"] = "active"
+	green.Spec.Template.Labels["volc.bytedance.com/statefulset"] = green.Name
+	if _, err := statefulSets.Create(context.Background(), green, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	blue.Annotations["volc.bytedance.com/blue-green-role"] = "retired"
+	if _, err := statefulSets.Update(context.Background(), blue, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.K8sClient.ClearActions()
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	if deleted := f.Deleted("statefulsets"); len(deleted) != 1 || deleted[0] != "db-deployment" {
+		t.Errorf("deleted statefulsets %v, want the retired db-deployment", deleted)
+	}
+	if created := f.Created("statefulsets"); len(created) != 0 {
+		t.Errorf("created %d statefulsets, want none", len(created))
+	}
+	got, err := f.CRClient.VolcV1alpha1().MySQLs("ns").Get(context.Background(), "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.BlueGreen == nil || got.Status.BlueGreen.Active != "db-deployment-green" {
+		t.Errorf("blue/green status = %+v, want db-deployment-green active", got.Status.BlueGreen)
+	}
+}
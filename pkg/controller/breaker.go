@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/klog/v2"
+
+	"github.com/cyhw/mysql-operator/pkg/metrics"
+)
+
+var breakerOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "mysql_operator_circuit_breaker_open",
+	Help: "Whether reconciles are paused after repeated failures to reach the API server.",
+})
+
+func init() {
+	metrics.MustRegister(breakerOpen)
+}
+
+// probeWait is how long reconciles wait for the outcome of the probe of a
+// half open breaker.
+var probeWait = time.Second
+
+// circuitBreaker pauses every reconcile once the API server failed
+// threshold reconciles in a row, instead of retrying each key against an
+// unreachable server. After the cooldown it is half open: a single reconcile
+// is let through as a probe while the others keep waiting, and it closes or
+// opens again with the outcome of the probe.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	lock      sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// newCircuitBreaker returns a breaker, which never opens with a threshold of
+// zero.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	breakerOpen.Set(0)
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// wait returns how long the reconcile is still paused, zero if it may run.
+// Once the cooldown expired, the first caller is let through as the probe and
+// must record its outcome.
+func (b *circuitBreaker) wait() time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.openUntil.IsZero() {
+		return 0
+	}
+	if wait := time.Until(b.openUntil); wait > 0 {
+		return wait
+	}
+	if b.probing {
+		return probeWait
+	}
+	b.probing = true
+	return 0
+}
+
+// record counts the outcome of a reconcile, and opens or closes the breaker.
+func (b *circuitBreaker) record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+	if !apiUnavailable(err) {
+		if !b.openUntil.IsZero() {
+			klog.InfoS("API server reachable again, resume reconciles.")
+			breakerOpen.Set(0)
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures < b.threshold {
+		return
+	}
+	// A failed probe opens it again right away.
+	b.failures = b.threshold - 1
+	b.openUntil = time.Now().Add(b.cooldown)
+	klog.InfoS("API server unreachable, pause reconciles.", "cooldown", b.cooldown, "err", err)
+	breakerOpen.Set(1)
+}
+
+// apiUnavailable reports whether err means the API server could not serve
+// the request, as opposed to refusing it.
+func apiUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) ||
+		utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err) ||
+		errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	cooldown := 50 * time.Millisecond
+	b := newCircuitBreaker(2, cooldown)
+	unavailable := apierrors.NewServiceUnavailable("down")
+
+	b.record(unavailable)
+	if wait := b.wait(); wait != 0 {
+		t.Fatalf("wait() = %s below the threshold, want 0", wait)
+	}
+	b.record(unavailable)
+	if wait := b.wait(); wait <= 0 {
+		t.Fatalf("wait() = %s once open, want the cooldown", wait)
+	}
+
+	// Half open: a single probe runs, and its failure opens it again.
+	time.Sleep(cooldown)
+	if wait := b.wait(); wait != 0 {
+		t.Fatalf("probe wait() = %s, want 0", wait)
+	}
+	if wait := b.wait(); wait != probeWait {
+		t.Errorf("wait() = %s during the probe, want %s", wait, probeWait)
+	}
+	b.record(unavailable)
+	if wait := b.wait(); wait <= 0 || wait > cooldown {
+		t.Fatalf("wait() = %s after a failed probe, want the cooldown", wait)
+	}
+
+	// A successful probe closes it.
+	time.Sleep(cooldown)
+	if wait := b.wait(); wait != 0 {
+		t.Fatalf("probe wait() = %s, want 0", wait)
+	}
+	b.record(nil)
+	for i := 0; i < 2; i++ {
+		if wait := b.wait(); wait != 0 {
+			t.Errorf("wait() = %s once closed, want 0", wait)
+		}
+	}
+}
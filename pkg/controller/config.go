@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	generatedConfigKey = "operator.cnf"
+	serverSection      = "mysqld"
+)
+
+// syncConfig converges the ConfigMap holding the my.cnf rendered from the
+// spec, or removes it when the spec sets no config.
+func (c *Controller) syncConfig(mysqlObj *mysqlalpha1.MySQL) error {
+	name := configMapName(mysqlObj)
+	current, err := c.configMapLister.ConfigMaps(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+
+	config := renderConfig(mysqlObj)
+	if config == "" {
+		if current == nil {
+			return nil
+		}
+		err = c.k8sClient.CoreV1().ConfigMaps(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete configmap.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+
+	data := map[string]string{
+		generatedConfigKey: config,
+	}
+	if current == nil {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: mysqlObj.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*newOwnerRef(mysqlObj),
+				},
+			},
+			Data: data,
+		}
+		_, err = c.k8sClient.CoreV1().ConfigMaps(mysqlObj.Namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create configmap.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if current.Data[generatedConfigKey] == config {
+		return nil
+	}
+	ret := current.DeepCopy()
+	ret.Data = data
+	_, err = c.k8sClient.CoreV1().ConfigMaps(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update configmap.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// configUpToDate reports whether the generated ConfigMap matches the spec.
+func (c *Controller) configUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	current, err := c.configMapLister.ConfigMaps(mysqlObj.Namespace).Get(configMapName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		return renderConfig(mysqlObj) == "", nil
+	}
+	if err != nil {
+		return false, err
+	}
+	config := renderConfig(mysqlObj)
+	return config != "" && current.Data[generatedConfigKey] == config, nil
+}
+
+func configMapName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-config"
+}
+
+// renderConfig renders the config of the spec as a my.cnf file, or returns an
+// empty string when the spec sets no config.
+func renderConfig(mysqlObj *mysqlalpha1.MySQL) string {
+	config := mysqlObj.Spec.Config
+	sections := map[string][]string{}
+	if config.MaxConnections != nil {
+		sections[serverSection] = append(sections[serverSection], fmt.Sprintf("max_connections = %d", *config.MaxConnections))
+	}
+	if config.InnodbBufferPoolSize != nil {
+		sections[serverSection] = append(sections[serverSection], fmt.Sprintf("innodb_buffer_pool_size = %d", config.InnodbBufferPoolSize.Value()))
+	}
+	if config.MaxAllowedPacket != nil {
+		sections[serverSection] = append(sections[serverSection], fmt.Sprintf("max_allowed_packet = %d", config.MaxAllowedPacket.Value()))
+	}
+	if mysqlObj.Spec.XProtocol.Enabled {
+		sections[serverSection] = append(sections[serverSection], "mysqlx = ON", fmt.Sprintf("mysqlx_port = %d", xProtocolPort))
+	}
+	if mysqlObj.Spec.AuditLog.Enabled {
+		sections[serverSection] = append(sections[serverSection], flavorOf(mysqlObj).auditLog(auditLogMountPath+"/audit.log")...)
+	}
+
+	// Options come last in their section so they win over the fields.
+	keys := make([]string, 0, len(config.Options))
+	for key := range config.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		section, name := serverSection, key
+		if i := strings.Index(key, "."); i >= 0 {
+			section, name = key[:i], key[i+1:]
+		}
+		sections[section] = append(sections[section], fmt.Sprintf("%s = %s", name, config.Options[key]))
+	}
+	if len(sections) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[%s]\n%s\n", name, strings.Join(sections[name], "\n"))
+	}
+	return b.String()
+}
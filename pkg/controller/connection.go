@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var rootUser = "root"
+
+// syncConnectionSecret keeps the connection Secret of a MySQL in line with its
+// root credentials, or removes it when it is no longer requested.
+func (c *Controller) syncConnectionSecret(mysqlObj *mysqlalpha1.MySQL) error {
+	name := connectionSecretName(mysqlObj)
+	current, err := c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+
+	if !mysqlObj.Spec.ConnectionSecret {
+		if current == nil {
+			return nil
+		}
+		err = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete connection secret.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+
+	root, err := c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	data := connectionSecretData(mysqlObj, root)
+
+	if current == nil {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: mysqlObj.Namespace,
+				Labels:    LabelsForInstance(mysqlObj.Name),
+				OwnerReferences: []metav1.OwnerReference{
+					*newOwnerRef(mysqlObj),
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}
+		_, err = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create connection secret.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	// Connection secrets created before they were labelled are invisible to
+	// the secret lister, so the label is added back as well.
+	if reflect.DeepEqual(current.Data, data) && SelectorForInstance(mysqlObj.Name).Matches(labels.Set(current.Labels)) {
+		return nil
+	}
+	ret := current.DeepCopy()
+	ret.Data = data
+	if ret.Labels == nil {
+		ret.Labels = map[string]string{}
+	}
+	for k, v := range LabelsForInstance(mysqlObj.Name) {
+		ret.Labels[k] = v
+	}
+	_, err = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update connection secret.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// connectionSecretUpToDate reports whether the connection Secret matches the
+// spec and the root credentials.
+func (c *Controller) connectionSecretUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	current, err := c.secretLister.Secrets(mysqlObj.Namespace).Get(connectionSecretName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		return !mysqlObj.Spec.ConnectionSecret, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !mysqlObj.Spec.ConnectionSecret {
+		return false, nil
+	}
+	root, err := c.secretLister.Secrets(mysqlObj.Namespace).Get(secretName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(current.Data, connectionSecretData(mysqlObj, root)), nil
+}
+
+// connectionSecretData returns the keys of the connection Secret. The X
+// Protocol does not go through the proxy, so it has its own host.
+func connectionSecretData(mysqlObj *mysqlalpha1.MySQL, root *corev1.Secret) map[string][]byte {
+	data := map[string][]byte{
+		"host":     []byte(clientHost(mysqlObj)),
+		"port":     []byte(fmt.Sprint(mysqlPort(mysqlObj))),
+		"username": []byte(rootUser),
+		"password": root.Data[envName],
+		"database": []byte(mysqlObj.Spec.Database),
+	}
+	if mysqlObj.Spec.XProtocol.Enabled {
+		data["xHost"] = []byte(clientServiceHost(mysqlObj))
+		data["xPort"] = []byte(fmt.Sprint(xProtocolPort))
+	}
+	return data
+}
+
+func connectionSecretName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-connection"
+}
@@ -2,19 +2,42 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	v1 "k8s.io/api/apps/v1"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	appsinformer "k8s.io/client-go/informers/apps/v1"
+	batchinformer "k8s.io/client-go/informers/batch/v1"
+	coreinformer "k8s.io/client-go/informers/core/v1"
+	networkinginformer "k8s.io/client-go/informers/networking/v1"
 	"k8s.io/client-go/kubernetes"
+	appslister "k8s.io/client-go/listers/apps/v1"
+	batchlister "k8s.io/client-go/listers/batch/v1"
+	corelister "k8s.io/client-go/listers/core/v1"
+	networkinglister "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/util/workqueue"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 
 	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
 	crclientset "github.com/cyhw/mysql-operator/pkg/clients/clientset/versioned"
 	crinformer "github.com/cyhw/mysql-operator/pkg/clients/informers/externalversions/mysql/v1alpha1"
+	crlister "github.com/cyhw/mysql-operator/pkg/clients/listers/mysql/v1alpha1"
 )
 
 var (
@@ -24,27 +47,168 @@ var (
 	replicas                      = int32(1)
 	terminationGracePeriodSeconds = int64(10)
 	containerName                 = "mysql"
-	imagePrefix                   = "arm64v8/mysql:"
 	volumeMountName               = "mysql-store"
 	volumeMoutPath                = "/var/lib/mysql"
 	envName                       = "MYSQL_ROOT_PASSWORD"
 	secretName                    = "mysql-password"
 	passwd                        = "bytedance"
-	port                          = int32(3306)
+	defaultPort                   = int32(3306)
+	portName                      = "mysql"
+	xProtocolPort                 = int32(33060)
+	xProtocolPortName             = "mysqlx"
+	zoneTopologyKey               = "topology.kubernetes.io/zone"
+	finalizerName                 = "volc.bytedance.com/cleanup"
+	specHashAnnotation            = "volc.bytedance.com/spec-hash"
+	passwordHashAnnotation        = "volc.bytedance.com/password-hash"
+	clusterDomain                 = "cluster.local"
+	defaultStorageRequest         = resource.MustParse("1Gi")
+	defaultStorageLimit           = resource.MustParse("2Gi")
+	binlogVolumeName              = "mysql-binlog"
+	binlogMountPath               = "/var/lib/mysql-binlog"
+	mysqlGroupID                  = int64(999)
+	configVolumeName              = "mysql-config"
+	configMountPath               = "/etc/mysql/conf.d"
+	configChecksumAnnotation      = "volc.bytedance.com/config-checksum"
+	auditLogVolumeName            = "mysql-audit"
+	auditLogMountPath             = "/var/log/mysql-audit"
+	defaultCharacterSet           = "utf8mb4"
+	defaultCollation              = "utf8mb4_unicode_ci"
+	instanceLabelKey              = "volc.bytedance.com/instance"
+	jobLabelKey                   = "volc.bytedance.com/job-instance"
+	jobKindLabelKey               = "volc.bytedance.com/job-kind"
 )
 
 type Controller struct {
-	k8sClient kubernetes.Interface
-	crClient  crclientset.Interface
-	crSynced  cache.InformerSynced
+	k8sClient           kubernetes.Interface
+	crClient            crclientset.Interface
+	dynamicClient       dynamic.Interface
+	crLister            crlister.MySQLLister
+	crSynced            cache.InformerSynced
+	restoreLister       crlister.MySQLRestoreLister
+	restoreSynced       cache.InformerSynced
+	statefulSetLister   appslister.StatefulSetLister
+	statefulSetSynced   cache.InformerSynced
+	cronJobLister       batchlister.CronJobLister
+	cronJobSynced       cache.InformerSynced
+	podLister           corelister.PodLister
+	podSynced           cache.InformerSynced
+	secretLister        corelister.SecretLister
+	secretSynced        cache.InformerSynced
+	serviceLister       corelister.ServiceLister
+	serviceSynced       cache.InformerSynced
+	claimLister         corelister.PersistentVolumeClaimLister
+	claimSynced         cache.InformerSynced
+	networkPolicyLister networkinglister.NetworkPolicyLister
+	networkPolicySynced cache.InformerSynced
+	configMapLister     corelister.ConfigMapLister
+	configMapSynced     cache.InformerSynced
+	deploymentLister    appslister.DeploymentLister
+	deploymentSynced    cache.InformerSynced
+	jobLister           batchlister.JobLister
+	jobSynced           cache.InformerSynced
+	eventBroadcaster    record.EventBroadcaster
+	recorder            record.EventRecorder
+	queue               workqueue.RateLimitingInterface
+	// keyLocks serializes the reconciles and the add handler of an object,
+	// which do not go through the queue.
+	keyLocks *keyMutex
+	// httpClient scrapes the exporter sidecars.
+	httpClient *http.Client
+	// readinessBackoff spaces the polls of a MySQL whose pods are not ready
+	// yet.
+	readinessBackoff workqueue.RateLimiter
+	// breaker pauses the reconciles while the API server is unreachable.
+	breaker *circuitBreaker
+	// bindingModes caches the volume binding mode of the storage classes,
+	// and topologyWarned the keys of the MySQLs warned about it.
+	bindingModes   sync.Map
+	topologyWarned sync.Map
+	// apiResources caches whether the optional resources are served.
+	apiResources sync.Map
+	options      Options
+}
+
+// Options tunes the behaviour of the controller.
+type Options struct {
+	// StatusResyncPeriod is the average period at which the status of every
+	// MySQL is recomputed even without events. Zero disables it.
+	StatusResyncPeriod time.Duration
+	// MaxReplicationLag is the replication lag above which a MySQL is
+	// Degraded and not Available.
+	MaxReplicationLag time.Duration
+	// SyncTimeout bounds the wait for the informer caches to sync. Zero
+	// waits forever.
+	SyncTimeout time.Duration
+	// ReadinessBackoffInitial and ReadinessBackoffMax bound the exponential
+	// backoff of the polls of a MySQL until its pods are ready, so its status
+	// converges without waiting for another event. Zero disables the polls.
+	ReadinessBackoffInitial time.Duration
+	ReadinessBackoffMax     time.Duration
+	// VersionsConfigMap is the namespace/name of the ConfigMap listing the
+	// versions spec.autoMinorUpgrade may move to, one key per flavor.
+	VersionsConfigMap string
+	// BreakerThreshold is the number of reconciles in a row failing to reach
+	// the API server after which all reconciles pause for BreakerCooldown.
+	// Zero disables it.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// MaxReplicas caps spec.replicas. A MySQL asking for more is reported
+	// Degraded and its StatefulSet left as is. Zero does not cap it.
+	MaxReplicas int32
+	// DisableStatusUpdates skips the writes of the status of MySQLs and
+	// MySQLRestores, e.g. to observe a shadow controller. The operations
+	// keeping their progress in the status are refused: restores are not
+	// started and the StatefulSets of blue/green upgrades are left as is.
+	DisableStatusUpdates bool
+	// Namespace is the namespace the informers are restricted to, all
+	// namespaces when empty. It only scopes the permission checks at startup.
+	Namespace string
 }
 
-func NewController(k8sClient kubernetes.Interface, crClient crclientset.Interface, crInformer crinformer.MySQLInformer) *Controller {
+// NewController creates the MySQL controller. The pod, secret, service, claim,
+// deployment and job informers are expected to be restricted to the objects
+// labelled by the controller, see ManagedListOptions.
+func NewController(k8sClient kubernetes.Interface, crClient crclientset.Interface, dynamicClient dynamic.Interface,
+	crInformer crinformer.MySQLInformer, restoreInformer crinformer.MySQLRestoreInformer, statefulSetInformer appsinformer.StatefulSetInformer, cronJobInformer batchinformer.CronJobInformer,
+	podInformer coreinformer.PodInformer, secretInformer coreinformer.SecretInformer, serviceInformer coreinformer.ServiceInformer, claimInformer coreinformer.PersistentVolumeClaimInformer,
+	networkPolicyInformer networkinginformer.NetworkPolicyInformer, configMapInformer coreinformer.ConfigMapInformer, deploymentInformer appsinformer.DeploymentInformer,
+	jobInformer batchinformer.JobInformer, options Options) *Controller {
 	controller := &Controller{
-		k8sClient: k8sClient,
-		crClient:  crClient,
-		crSynced:  crInformer.Informer().HasSynced,
+		k8sClient:           k8sClient,
+		crClient:            crClient,
+		dynamicClient:       dynamicClient,
+		crLister:            crInformer.Lister(),
+		crSynced:            crInformer.Informer().HasSynced,
+		restoreLister:       restoreInformer.Lister(),
+		restoreSynced:       restoreInformer.Informer().HasSynced,
+		statefulSetLister:   statefulSetInformer.Lister(),
+		statefulSetSynced:   statefulSetInformer.Informer().HasSynced,
+		cronJobLister:       cronJobInformer.Lister(),
+		cronJobSynced:       cronJobInformer.Informer().HasSynced,
+		podLister:           podInformer.Lister(),
+		podSynced:           podInformer.Informer().HasSynced,
+		secretLister:        secretInformer.Lister(),
+		secretSynced:        secretInformer.Informer().HasSynced,
+		serviceLister:       serviceInformer.Lister(),
+		serviceSynced:       serviceInformer.Informer().HasSynced,
+		claimLister:         claimInformer.Lister(),
+		claimSynced:         claimInformer.Informer().HasSynced,
+		networkPolicyLister: networkPolicyInformer.Lister(),
+		networkPolicySynced: networkPolicyInformer.Informer().HasSynced,
+		configMapLister:     configMapInformer.Lister(),
+		configMapSynced:     configMapInformer.Informer().HasSynced,
+		deploymentLister:    deploymentInformer.Lister(),
+		deploymentSynced:    deploymentInformer.Informer().HasSynced,
+		jobLister:           jobInformer.Lister(),
+		jobSynced:           jobInformer.Informer().HasSynced,
+		queue:               workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "mysql"),
+		keyLocks:            newKeyMutex(),
+		httpClient:          &http.Client{Timeout: exporterScrapeTimeout},
+		readinessBackoff:    workqueue.NewItemExponentialFailureRateLimiter(options.ReadinessBackoffInitial, options.ReadinessBackoffMax),
+		breaker:             newCircuitBreaker(options.BreakerThreshold, options.BreakerCooldown),
+		options:             options,
 	}
+	controller.eventBroadcaster, controller.recorder = newEventRecorder(k8sClient)
 
 	klog.InfoS("Set up event handlers.")
 	crInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -52,191 +216,450 @@ func NewController(k8sClient kubernetes.Interface, crClient crclientset.Interfac
 		UpdateFunc: controller.update,
 		DeleteFunc: controller.delete,
 	})
+	childHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleObject,
+		UpdateFunc: func(old, new interface{}) {
+			controller.handleObject(new)
+		},
+		DeleteFunc: controller.handleObject,
+	}
+	restoreInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleRestore,
+		UpdateFunc: func(old, new interface{}) {
+			controller.handleRestore(new)
+		},
+		DeleteFunc: controller.handleRestore,
+	})
+	statefulSetInformer.Informer().AddEventHandler(childHandler)
+	cronJobInformer.Informer().AddEventHandler(childHandler)
+	podInformer.Informer().AddEventHandler(childHandler)
+	networkPolicyInformer.Informer().AddEventHandler(childHandler)
+	deploymentInformer.Informer().AddEventHandler(childHandler)
+	jobInformer.Informer().AddEventHandler(childHandler)
+	secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleSecret,
+		UpdateFunc: func(old, new interface{}) {
+			controller.handleSecret(new)
+		},
+		DeleteFunc: controller.handleSecret,
+	})
+	serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleService,
+		UpdateFunc: func(old, new interface{}) {
+			controller.handleService(new)
+		},
+		DeleteFunc: controller.handleService,
+	})
+	claimInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleClaim,
+		UpdateFunc: func(old, new interface{}) {
+			controller.handleClaim(new)
+		},
+		DeleteFunc: controller.handleClaim,
+	})
+	configMapInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleConfigMap,
+		UpdateFunc: func(old, new interface{}) {
+			controller.handleConfigMap(new)
+		},
+		DeleteFunc: controller.handleConfigMap,
+	})
 
 	return controller
 }
 
 func (c *Controller) Run(stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+	defer c.eventBroadcaster.Shutdown()
+
 	klog.InfoS("Run controller.")
 
+	c.checkPermissions(context.Background())
+
 	klog.InfoS("Wait for informer cache to sync.")
-	if ok := cache.WaitForCacheSync(stopCh, c.crSynced); !ok {
+	// Without a timeout, missing permissions to list a resource would block
+	// here forever.
+	var syncCtx context.Context
+	var cancel context.CancelFunc
+	if c.options.SyncTimeout > 0 {
+		syncCtx, cancel = context.WithTimeout(context.Background(), c.options.SyncTimeout)
+	} else {
+		syncCtx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-syncCtx.Done():
+		}
+	}()
+	if ok := cache.WaitForCacheSync(syncCtx.Done(), c.crSynced, c.restoreSynced, c.statefulSetSynced, c.cronJobSynced, c.podSynced, c.secretSynced, c.serviceSynced, c.claimSynced,
+		c.networkPolicySynced, c.configMapSynced, c.deploymentSynced, c.jobSynced); !ok {
+		if errors.Is(syncCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("caches did not sync within %s, check that the operator may list and watch its resources", c.options.SyncTimeout)
+		}
 		return errors.New("Failed to wait for caches to sync.")
 	}
 
 	klog.InfoS("Start worker.")
+	go wait.Until(c.runWorker, time.Second, stopCh)
 	<-stopCh
 	klog.InfoS("Shut down.")
 
 	return nil
 }
 
-func (c *Controller) add(obj interface{}) {
-	klog.InfoS("Receive ADD Event.")
+func (c *Controller) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
 
-	mysqlObj, ok := obj.(*mysqlalpha1.MySQL)
-	if !ok {
-		klog.Errorf("Failed to type assert object: %v", obj)
-		return
+func (c *Controller) processNextWorkItem() bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
 	}
-	klog.InfoS("obj", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "version", mysqlObj.Spec.Version)
+	defer c.queue.Done(item)
 
-	ret := mysqlObj.DeepCopy()
-	ret.Status.Message = "Received In ADD"
-	_, err := c.crClient.VolcV1alpha1().MySQLs(ret.Namespace).UpdateStatus(context.TODO(), ret, metav1.UpdateOptions{})
+	key := item.(string)
+	if wait := c.breaker.wait(); wait > 0 {
+		c.queue.AddAfter(key, wait)
+		return true
+	}
+	err := c.reconcile(context.Background(), key)
+	c.breaker.record(err)
 	if err != nil {
-		klog.ErrorS(err, "Failed to update status", "namespace", ret.Namespace, "name", ret.Name)
-		return
+		klog.ErrorS(err, "Failed to sync", "key", key)
+		c.queue.AddRateLimited(key)
+		return true
 	}
-	klog.InfoS("Update Status.", "namespace", ret.Namespace, "name", ret.Name, "version", mysqlObj.Spec.Version)
+	c.queue.Forget(key)
+	return true
+}
 
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: secretName,
-		},
-		Type: corev1.SecretTypeOpaque,
-		StringData: map[string]string{
-			envName: passwd,
-		},
+// Reconcile reconciles the MySQL of the key once, outside of the workqueue.
+func (c *Controller) Reconcile(ctx context.Context, key string) error {
+	return c.reconcile(ctx, key)
+}
+
+// reconcile converges the children of the MySQL of the key with its spec.
+// It is idempotent, the event handlers only enqueue keys. A MySQL that is gone
+// has its children removed.
+func (c *Controller) reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.ErrorS(err, "Invalid key", "key", key)
+		return nil
+	}
+	c.keyLocks.Lock(key)
+	defer c.keyLocks.Unlock(key)
+
+	mysqlObj, err := c.crLister.MySQLs(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		// Children are normally removed by the finalizer; this covers
+		// MySQLs whose finalizer was removed by hand. The shared Secret
+		// and headless Service are kept while other MySQLs of the
+		// namespace use them.
+		c.cleanup(&mysqlalpha1.MySQL{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}})
+		return nil
 	}
-	_, err = c.k8sClient.CoreV1().Secrets(ret.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
 	if err != nil {
-		ret.Status.Message = "Failed"
-		_, err = c.crClient.VolcV1alpha1().MySQLs(ret.Namespace).UpdateStatus(context.TODO(), ret, metav1.UpdateOptions{})
+		return err
+	}
+
+	if mysqlObj.DeletionTimestamp != nil {
+		return c.finalize(mysqlObj)
+	}
+	if !containsString(mysqlObj.Finalizers, finalizerName) {
+		mysqlObj = mysqlObj.DeepCopy()
+		mysqlObj.Finalizers = append(mysqlObj.Finalizers, finalizerName)
+		mysqlObj, err = c.crClient.VolcV1alpha1().MySQLs(namespace).Update(ctx, mysqlObj, metav1.UpdateOptions{})
 		if err != nil {
-			klog.ErrorS(err, "Failed to update status", "namespace", ret.Namespace, "name", ret.Name)
-			return
+			return err
 		}
-		klog.ErrorS(err, "Failed to create secret", "namespace", ret.Namespace, "name", secretName)
-		return
+		klog.InfoS("Add finalizer.", "namespace", namespace, "name", name)
 	}
 
-	service := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: serviceName,
-			Labels: map[string]string{
-				matchLabelKey: matchLabelVal,
-			},
-		},
-		Spec: corev1.ServiceSpec{
-			Ports: []corev1.ServicePort{
-				{
-					Port: port,
-				},
-			},
-			ClusterIP: "None",
-			Selector: map[string]string{
-				matchLabelKey: matchLabelVal,
-			},
-		},
+	// The new version is rolled out by the reconcile of the updated spec.
+	if upgraded, err := c.autoMinorUpgrade(mysqlObj); err != nil || upgraded {
+		return err
 	}
-	_, err = c.k8sClient.CoreV1().Services(ret.Namespace).Create(context.Background(), service, metav1.CreateOptions{})
+
+	// The spec of the copy is defaulted, only its status is written back.
+	ret := withDefaults(mysqlObj)
+	if err := c.observeBlueGreen(ret); err != nil {
+		return err
+	}
+	upToDate, err := c.upToDate(ret)
 	if err != nil {
-		ret.Status.Message = "Failed"
-		_, err = c.crClient.VolcV1alpha1().MySQLs(ret.Namespace).UpdateStatus(context.TODO(), ret, metav1.UpdateOptions{})
+		return err
+	}
+
+	phases := []struct {
+		name string
+		sync func(*mysqlalpha1.MySQL) error
+		// observe marks the phases that follow the state of the children
+		// rather than the spec, which run even when the spec was reconciled.
+		observe bool
+	}{
+		{phaseRestore, c.syncRestore, true},
+		{phaseSecret, c.syncSecret, false},
+		{phaseConfig, c.syncConfig, false},
+		{phaseStartupScript, c.syncStartupScript, false},
+		{phaseServices, c.syncServices, false},
+		{phasePodGroup, c.syncPodGroup, false},
+		{phaseStatefulSet, c.syncStatefulSet, false},
+		{phaseBackup, c.syncBackup, false},
+		{phaseConnectionSecret, c.syncConnectionSecret, false},
+		{phaseNetworkPolicy, c.syncNetworkPolicy, false},
+		{phaseMonitoring, c.syncMonitoring, false},
+		{phaseProxy, c.syncProxy, false},
+		{phaseOrphans, c.syncOrphans, true},
+		{phaseSnapshot, c.syncSnapshot, true},
+		{phaseOnDemandBackup, c.syncOnDemandBackup, true},
+		{phaseBackupJobs, c.syncBackupJobs, true},
+		{phaseStatus, c.syncStatus, true},
+		{phaseResources, c.syncResources, true},
+		{phaseAvailable, c.syncAvailable, true},
+	}
+	for _, phase := range phases {
+		if upToDate && !phase.observe {
+			continue
+		}
+		start := time.Now()
+		err = phase.sync(ret)
+		observePhase(phase.name, start)
+		if err != nil {
+			return err
+		}
+	}
+	ret.Status.ObservedGeneration = ret.Generation
+	if c.options.DisableStatusUpdates {
+		klog.V(2).InfoS("Skip status update.", "namespace", ret.Namespace, "name", ret.Name, "status", ret.Status)
+	} else if !equality.Semantic.DeepEqual(ret.Status, mysqlObj.Status) {
+		_, err = c.crClient.VolcV1alpha1().MySQLs(ret.Namespace).UpdateStatus(ctx, ret, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.options.StatusResyncPeriod > 0 {
+		// Jitter spreads the resyncs of instances created together.
+		c.queue.AddAfter(key, wait.Jitter(c.options.StatusResyncPeriod, 0.2))
+	}
+	return nil
+}
+
+// upToDate reports whether the spec generation was already reconciled and the
+// children are still in the state it asks for, in which case the phases
+// converging them can be skipped.
+func (c *Controller) upToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	if mysqlObj.Generation != mysqlObj.Status.ObservedGeneration {
+		return false, nil
+	}
+	for _, check := range []func(*mysqlalpha1.MySQL) (bool, error){
+		c.secretUpToDate,
+		c.configUpToDate,
+		c.startupScriptUpToDate,
+		c.servicesUpToDate,
+		c.statefulSetUpToDate,
+		c.backupUpToDate,
+		c.connectionSecretUpToDate,
+		c.networkPolicyUpToDate,
+		c.monitoringUpToDate,
+		c.proxyUpToDate,
+	} {
+		ok, err := check(mysqlObj)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get key", "obj", obj)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// handleObject enqueues the MySQL owning the given child object.
+func (c *Controller) handleObject(obj interface{}) {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Failed to type assert object: %v", obj)
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			klog.Errorf("Failed to type assert tombstone object: %v", tombstone.Obj)
+			return
+		}
+	}
+
+	ownerRef := metav1.GetControllerOf(object)
+	switch {
+	case ownerRef != nil && ownerRef.Kind == "StatefulSet":
+		// Pods are owned by the StatefulSet, which is owned by the MySQL.
+		sts, err := c.statefulSetLister.StatefulSets(object.GetNamespace()).Get(ownerRef.Name)
+		if err != nil {
+			return
+		}
+		ownerRef = metav1.GetControllerOf(sts)
+	case ownerRef != nil && ownerRef.Kind == "CronJob":
+		// Scheduled backup Jobs are owned by the backup CronJob.
+		cronJob, err := c.cronJobLister.CronJobs(object.GetNamespace()).Get(ownerRef.Name)
 		if err != nil {
-			klog.ErrorS(err, "Failed to update status", "namespace", ret.Namespace, "name", ret.Name)
 			return
 		}
-		_ = c.k8sClient.CoreV1().Secrets(ret.Namespace).Delete(context.Background(), secretName, metav1.DeleteOptions{})
-		klog.ErrorS(err, "Failed to create service", "namespace", ret.Namespace, "name", ret.Name)
+		ownerRef = metav1.GetControllerOf(cronJob)
+	}
+	if ownerRef == nil || ownerRef.Kind != "MySQL" {
+		return
+	}
+	mysqlObj, err := c.crLister.MySQLs(object.GetNamespace()).Get(ownerRef.Name)
+	if err != nil {
 		return
 	}
+	c.enqueue(mysqlObj)
+}
 
-	podTemplate := corev1.PodTemplateSpec{
-		ObjectMeta: metav1.ObjectMeta{
-			Labels: map[string]string{
-				matchLabelKey: matchLabelVal,
-			},
-		},
-		Spec: corev1.PodSpec{
-			TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
-			Containers: []corev1.Container{
-				{
-					Name:  containerName,
-					Image: imagePrefix + ret.Spec.Version,
-					Ports: []corev1.ContainerPort{
-						{
-							ContainerPort: port,
-						},
-					},
-					VolumeMounts: []corev1.VolumeMount{
-						{
-							Name:      volumeMountName,
-							MountPath: volumeMoutPath,
-						},
-					},
-					Env: []corev1.EnvVar{
-						{
-							Name: envName,
-							ValueFrom: &corev1.EnvVarSource{
-								SecretKeyRef: &corev1.SecretKeySelector{
-									LocalObjectReference: corev1.LocalObjectReference{
-										Name: secretName,
-									},
-									Key: envName,
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+// handleSecret enqueues the MySQLs sharing the password Secret, or the MySQL
+// owning another Secret.
+func (c *Controller) handleSecret(obj interface{}) {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Failed to type assert object: %v", obj)
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			klog.Errorf("Failed to type assert tombstone object: %v", tombstone.Obj)
+			return
+		}
+	}
+	if object.GetName() != secretName {
+		c.handleObject(object)
+		return
 	}
 
-	vcTemplate := []corev1.PersistentVolumeClaim{
-		{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: volumeMountName,
-			},
-			Spec: corev1.PersistentVolumeClaimSpec{
-				AccessModes: []corev1.PersistentVolumeAccessMode{
-					corev1.ReadWriteOnce,
-				},
-				Resources: corev1.ResourceRequirements{
-					Requests: corev1.ResourceList{
-						corev1.ResourceStorage: {
-							Format: "1Gi",
-						},
-					},
-					Limits: map[corev1.ResourceName]resource.Quantity{
-						corev1.ResourceStorage: {
-							Format: "2Gi",
-						},
-					},
-				},
-			},
-		},
+	mysqlObjs, err := c.crLister.MySQLs(object.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, mysqlObj := range mysqlObjs {
+		c.enqueue(mysqlObj)
 	}
+}
 
-	sts := &v1.StatefulSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      ret.Name + "-deployment",
-			Namespace: ret.Namespace,
-		},
-		Spec: v1.StatefulSetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					matchLabelKey: matchLabelVal,
-				},
-			},
-			ServiceName:          serviceName,
-			Replicas:             &replicas,
-			Template:             podTemplate,
-			VolumeClaimTemplates: vcTemplate,
-		},
+// handleService enqueues the MySQLs sharing the headless Service, or the MySQL
+// owning the client Service.
+func (c *Controller) handleService(obj interface{}) {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Failed to type assert object: %v", obj)
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			klog.Errorf("Failed to type assert tombstone object: %v", tombstone.Obj)
+			return
+		}
+	}
+	if object.GetName() != serviceName {
+		c.handleObject(object)
+		return
 	}
-	_, err = c.k8sClient.AppsV1().StatefulSets(ret.Namespace).Create(context.Background(), sts, metav1.CreateOptions{})
+
+	mysqlObjs, err := c.crLister.MySQLs(object.GetNamespace()).List(labels.Everything())
 	if err != nil {
-		ret.Status.Message = "Failed"
-		_, err = c.crClient.VolcV1alpha1().MySQLs(ret.Namespace).UpdateStatus(context.TODO(), ret, metav1.UpdateOptions{})
-		if err != nil {
-			klog.ErrorS(err, "Failed to update status", "namespace", ret.Namespace, "name", ret.Name)
+		return
+	}
+	for _, mysqlObj := range mysqlObjs {
+		c.enqueue(mysqlObj)
+	}
+}
+
+// handleClaim enqueues the MySQL whose StatefulSet created the claim. The
+// StatefulSet controller does not set owner references on claims, so the
+// MySQL is found from the claim name.
+func (c *Controller) handleClaim(obj interface{}) {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Failed to type assert object: %v", obj)
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			klog.Errorf("Failed to type assert tombstone object: %v", tombstone.Obj)
+			return
+		}
+	}
+
+	mysqlObjs, err := c.crLister.MySQLs(object.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, mysqlObj := range mysqlObjs {
+		if isClaimOfMySQL(mysqlObj, object.GetName()) {
+			c.enqueue(mysqlObj)
+		}
+	}
+}
+
+// handleConfigMap enqueues the MySQLs mounting the ConfigMap.
+func (c *Controller) handleConfigMap(obj interface{}) {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Failed to type assert object: %v", obj)
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			klog.Errorf("Failed to type assert tombstone object: %v", tombstone.Obj)
 			return
 		}
-		_ = c.k8sClient.CoreV1().Secrets(ret.Namespace).Delete(context.Background(), secretName, metav1.DeleteOptions{})
-		_ = c.k8sClient.CoreV1().Services(ret.Namespace).Delete(context.Background(), serviceName, metav1.DeleteOptions{})
 	}
+
+	mysqlObjs, err := c.crLister.MySQLs(object.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	versions := object.GetNamespace()+"/"+object.GetName() == c.options.VersionsConfigMap
+	for _, mysqlObj := range mysqlObjs {
+		if mysqlObj.Spec.ConfigMapName == object.GetName() || versions && mysqlObj.Spec.AutoMinorUpgrade {
+			c.enqueue(mysqlObj)
+		}
+	}
+}
+
+func (c *Controller) enqueueAfter(obj interface{}, duration time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get key", "obj", obj)
+		return
+	}
+	c.queue.AddAfter(key, duration)
+}
+
+func (c *Controller) add(obj interface{}) {
+	klog.InfoS("Receive ADD Event.")
+	c.enqueue(obj)
 }
 
 func (c *Controller) update(old, new interface{}) {
@@ -255,20 +678,296 @@ func (c *Controller) update(old, new interface{}) {
 		return
 	}
 	klog.InfoS("new", "namespace", newObj.Namespace, "name", newObj.Name, "version", newObj.Spec.Version)
+
+	if !needsReconcile(oldObj, newObj) {
+		return
+	}
+	c.enqueue(newObj)
+}
+
+// needsReconcile reports whether an update changed the spec or the metadata
+// the reconcile depends on. Status writes, most of them made by the
+// controller itself, are ignored.
+func needsReconcile(oldObj, newObj *mysqlalpha1.MySQL) bool {
+	return oldObj.Generation != newObj.Generation ||
+		!equality.Semantic.DeepEqual(oldObj.DeletionTimestamp, newObj.DeletionTimestamp) ||
+		!equality.Semantic.DeepEqual(oldObj.Finalizers, newObj.Finalizers) ||
+		!equality.Semantic.DeepEqual(oldObj.Labels, newObj.Labels) ||
+		!equality.Semantic.DeepEqual(oldObj.Annotations, newObj.Annotations)
 }
 
+// delete enqueues the key of a deleted MySQL, which the reconcile finds gone.
+// A delete missed by the watch is delivered as a tombstone, whose key is the
+// one of the object it holds.
 func (c *Controller) delete(obj interface{}) {
 	klog.InfoS("Receive DELETE Event.")
 
-	mysqlObj, ok := obj.(*mysqlalpha1.MySQL)
-	if !ok {
-		klog.Errorf("Failed to type assert object: %v", obj)
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get key", "obj", obj)
 		return
 	}
-	klog.InfoS("obj", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "version", mysqlObj.Spec.Version)
+	c.queue.Add(key)
+}
+
+// ManagedListOptions restricts an informer to the objects labelled by the
+// controller.
+func ManagedListOptions(options *metav1.ListOptions) {
+	options.LabelSelector = labels.SelectorFromSet(sharedLabels()).String()
+}
+
+// sharedLabels returns the labels of the children shared by the MySQLs of a
+// namespace, the password Secret and the headless Service, which an instance
+// label would tie to one of them. The children of an instance carry them too.
+func sharedLabels() map[string]string {
+	return map[string]string{
+		matchLabelKey: matchLabelVal,
+	}
+}
+
+// LabelsForInstance returns the labels of the children of the named MySQL.
+func LabelsForInstance(name string) map[string]string {
+	ret := sharedLabels()
+	ret[instanceLabelKey] = name
+	return ret
+}
+
+// SelectorForInstance selects the children of the named MySQL.
+func SelectorForInstance(name string) labels.Selector {
+	return labels.SelectorFromSet(LabelsForInstance(name))
+}
+
+// headlessServiceName returns the name of the headless Service governing the
+// StatefulSet. Both must agree for the DNS records of the pods to exist.
+func headlessServiceName(mysqlObj *mysqlalpha1.MySQL) string {
+	return serviceName
+}
+
+func newOwnerRef(mysqlObj *mysqlalpha1.MySQL) *metav1.OwnerReference {
+	return metav1.NewControllerRef(mysqlObj, mysqlalpha1.SchemeGroupVersion.WithKind("MySQL"))
+}
+
+// hashObject returns a short hash of the JSON encoding of obj. API server
+// defaulting makes comparing live and desired specs unreliable, so children
+// record the hash of the spec they were created from instead.
+func hashObject(obj interface{}) string {
+	data, _ := json.Marshal(obj)
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(data)
+	return fmt.Sprintf("%08x", hasher.Sum32())
+}
+
+func mysqlEnv(mysqlObj *mysqlalpha1.MySQL) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		rootPasswordEnv(mysqlObj),
+	}
+	if mysqlObj.Spec.Database != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  flavorOf(mysqlObj).databaseEnv,
+			Value: mysqlObj.Spec.Database,
+		})
+	}
+	return env
+}
+
+// rootPasswordEnv returns the variable of the flavor holding the root
+// password, read from the envName key of the password Secret.
+func rootPasswordEnv(mysqlObj *mysqlalpha1.MySQL) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: flavorOf(mysqlObj).passwordEnv,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: secretName,
+				},
+				Key: envName,
+			},
+		},
+	}
+}
+
+// mergeAnnotations merges the user annotations with the ones managed by the
+// controller, which win on conflicts.
+func mergeAnnotations(user, managed map[string]string) map[string]string {
+	if len(user) == 0 && len(managed) == 0 {
+		return nil
+	}
+	ret := make(map[string]string, len(user)+len(managed))
+	for k, v := range user {
+		ret[k] = v
+	}
+	for k, v := range managed {
+		ret[k] = v
+	}
+	return ret
+}
+
+func mysqlArgs(mysqlObj *mysqlalpha1.MySQL) []string {
+	args := []string{
+		"--character-set-server=" + mysqlObj.Spec.CharacterSet,
+		"--collation-server=" + mysqlObj.Spec.Collation,
+	}
+	// The flag is left out for the default so existing pods are not rolled.
+	if mysqlObj.Spec.DataDir != volumeMoutPath {
+		args = append(args, "--datadir="+mysqlObj.Spec.DataDir)
+	}
+	if mysqlPort(mysqlObj) != defaultPort {
+		args = append(args, fmt.Sprintf("--port=%d", mysqlPort(mysqlObj)))
+	}
+	if mysqlObj.Spec.BinlogStorage != nil {
+		args = append(args, "--log-bin="+binlogMountPath+"/mysql-bin")
+	}
+	return args
+}
+
+func volumeMounts(mysqlObj *mysqlalpha1.MySQL) []corev1.VolumeMount {
+	mounts := []corev1.VolumeMount{
+		{
+			Name:      volumeMountName,
+			MountPath: mysqlObj.Spec.DataDir,
+			SubPath:   mysqlObj.Spec.DataSubPath,
+		},
+	}
+	if mysqlObj.Spec.BinlogStorage != nil {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      binlogVolumeName,
+			MountPath: binlogMountPath,
+		})
+	}
+	if len(configSources(mysqlObj)) > 0 {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      configVolumeName,
+			MountPath: configMountPath,
+			ReadOnly:  true,
+		})
+	}
+	if mysqlObj.Spec.AuditLog.Enabled {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      auditLogVolumeName,
+			MountPath: auditLogMountPath,
+		})
+	}
+	if mysqlObj.Spec.StartupScript != "" {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      startupScriptVolumeName,
+			MountPath: startupScriptMountPath,
+			SubPath:   startupScriptKey,
+			ReadOnly:  true,
+		})
+	}
+	for i, m := range mysqlObj.Spec.ExtraSecretMounts {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      extraSecretVolumeName(i),
+			MountPath: m.MountPath,
+			ReadOnly:  true,
+		})
+	}
+	return mounts
+}
+
+// mysqlPort returns the port of the MySQL, the default when unset.
+func mysqlPort(mysqlObj *mysqlalpha1.MySQL) int32 {
+	if mysqlObj.Spec.Port != 0 {
+		return mysqlObj.Spec.Port
+	}
+	return defaultPort
+}
+
+// portArg returns the client flag selecting the port of the MySQL. It is
+// empty for the default, so the commands of existing children are unchanged.
+func portArg(mysqlObj *mysqlalpha1.MySQL) string {
+	if mysqlPort(mysqlObj) == defaultPort {
+		return ""
+	}
+	return fmt.Sprintf(" -P %d", mysqlPort(mysqlObj))
+}
+
+// extraSecretVolumeName names the volume of an extra Secret by its index, as
+// Secret names are not always valid volume names.
+func extraSecretVolumeName(i int) string {
+	return fmt.Sprintf("extra-secret-%d", i)
+}
+
+func volumeClaimTemplates(mysqlObj *mysqlalpha1.MySQL) []corev1.PersistentVolumeClaim {
+	if mysqlObj.Spec.Ephemeral {
+		return nil
+	}
+	templates := []corev1.PersistentVolumeClaim{
+		newVolumeClaimTemplate(mysqlObj, volumeMountName, mysqlObj.Spec.Storage),
+	}
+	if mysqlObj.Spec.BinlogStorage != nil {
+		templates = append(templates, newVolumeClaimTemplate(mysqlObj, binlogVolumeName, *mysqlObj.Spec.BinlogStorage))
+	}
+	return templates
+}
+
+// newVolumeClaimTemplate returns a claim template labelled and annotated with
+// spec.pvcLabels and spec.pvcAnnotations. The StatefulSet controller adds the
+// pod selector labels to the claims.
+func newVolumeClaimTemplate(mysqlObj *mysqlalpha1.MySQL, name string, storage mysqlalpha1.MySQLStorageSpec) corev1.PersistentVolumeClaim {
+	return corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      claimLabels(mysqlObj),
+			Annotations: copyMap(mysqlObj.Spec.PVCAnnotations),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{
+				corev1.ReadWriteOnce,
+			},
+			StorageClassName: storage.StorageClassName,
+			Resources:        storageResources(storage),
+		},
+	}
+}
+
+// claimLabels returns the labels of the volume claim templates. The instance
+// labels win over the user labels, the claims are selected by them.
+func claimLabels(mysqlObj *mysqlalpha1.MySQL) map[string]string {
+	ret := LabelsForInstance(mysqlObj.Name)
+	for k, v := range mysqlObj.Spec.PVCLabels {
+		if _, ok := ret[k]; !ok {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// copyMap returns a copy of m, nil when it is empty.
+func copyMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	ret := make(map[string]string, len(m))
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
+
+func storageResources(storage mysqlalpha1.MySQLStorageSpec) corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceStorage: *storage.Request,
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceStorage: *storage.Limit,
+		},
+	}
+}
 
-	_ = c.crClient.VolcV1alpha1().MySQLs(mysqlObj.Namespace).Delete(context.TODO(), mysqlObj.Name, metav1.DeleteOptions{})
-	_ = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Delete(context.Background(), secretName, metav1.DeleteOptions{})
-	_ = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Delete(context.Background(), serviceName, metav1.DeleteOptions{})
-	_ = c.k8sClient.AppsV1().StatefulSets(mysqlObj.Namespace).Delete(context.Background(), mysqlObj.Name+"-deployment", metav1.DeleteOptions{})
+func topologySpreadConstraints(mysqlObj *mysqlalpha1.MySQL, replicas int32) []corev1.TopologySpreadConstraint {
+	if len(mysqlObj.Spec.TopologySpreadConstraints) > 0 || replicas <= 1 {
+		return mysqlObj.Spec.TopologySpreadConstraints
+	}
+	return []corev1.TopologySpreadConstraint{
+		{
+			MaxSkew:           1,
+			TopologyKey:       zoneTopologyKey,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: LabelsForInstance(mysqlObj.Name),
+			},
+		},
+	}
 }
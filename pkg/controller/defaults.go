@@ -0,0 +1,110 @@
+package controller
+
+import (
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	livenessProbeDefaults = mysqlalpha1.MySQLProbeSpec{
+		Mode:             mysqlalpha1.ProbeModePing,
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		FailureThreshold: 3,
+	}
+	readinessProbeDefaults = mysqlalpha1.MySQLProbeSpec{
+		Mode:             mysqlalpha1.ProbeModeQuery,
+		PeriodSeconds:    5,
+		TimeoutSeconds:   5,
+		FailureThreshold: 3,
+	}
+	startupProbeDefaults = mysqlalpha1.MySQLProbeSpec{
+		Mode:             mysqlalpha1.ProbeModePing,
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		FailureThreshold: 30,
+	}
+	successfulBackupsHistoryLimit = int32(3)
+	failedBackupsHistoryLimit     = int32(1)
+)
+
+// withDefaults returns a copy of the MySQL with the optional fields of its
+// spec defaulted, so the reconcile does not need to check them. Pointer fields
+// whose absence has a meaning of its own, such as BinlogStorage or
+// NetworkPolicy, are left nil.
+func withDefaults(mysqlObj *mysqlalpha1.MySQL) *mysqlalpha1.MySQL {
+	ret := mysqlObj.DeepCopy()
+	spec := &ret.Spec
+	if spec.Flavor == "" {
+		spec.Flavor = mysqlalpha1.FlavorMySQL
+	}
+	if spec.Replicas == nil {
+		desired := replicas
+		spec.Replicas = &desired
+	}
+	if spec.DeletionPolicy == "" {
+		spec.DeletionPolicy = mysqlalpha1.DeletionPolicyDelete
+	}
+	if spec.CharacterSet == "" {
+		spec.CharacterSet = defaultCharacterSet
+	}
+	if spec.Collation == "" {
+		spec.Collation = defaultCollation
+	}
+	if spec.DataDir == "" {
+		spec.DataDir = volumeMoutPath
+	}
+	if spec.Port == 0 {
+		spec.Port = defaultPort
+	}
+	defaultProbe(&spec.Probes.Liveness, livenessProbeDefaults)
+	defaultProbe(&spec.Probes.Readiness, readinessProbeDefaults)
+	defaultProbe(&spec.Probes.Startup, startupProbeDefaults)
+	defaultStorage(&spec.Storage)
+	if spec.Backup.HistoryLimit.Successful == nil {
+		limit := successfulBackupsHistoryLimit
+		spec.Backup.HistoryLimit.Successful = &limit
+	}
+	if spec.Backup.HistoryLimit.Failed == nil {
+		limit := failedBackupsHistoryLimit
+		spec.Backup.HistoryLimit.Failed = &limit
+	}
+	if spec.BinlogStorage != nil {
+		defaultStorage(spec.BinlogStorage)
+	}
+	return ret
+}
+
+// defaultProbe fills the mode and zero timings of a probe from the defaults.
+func defaultProbe(probe *mysqlalpha1.MySQLProbeSpec, defaults mysqlalpha1.MySQLProbeSpec) {
+	if probe.Mode == "" {
+		probe.Mode = defaults.Mode
+	}
+	if probe.InitialDelaySeconds == 0 {
+		probe.InitialDelaySeconds = defaults.InitialDelaySeconds
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = defaults.PeriodSeconds
+	}
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = defaults.TimeoutSeconds
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = defaults.FailureThreshold
+	}
+}
+
+// defaultStorage uses the size given for both the request and the limit, or
+// the default sizes when none is given.
+func defaultStorage(storage *mysqlalpha1.MySQLStorageSpec) {
+	switch {
+	case storage.Request == nil && storage.Limit == nil:
+		request, limit := defaultStorageRequest.DeepCopy(), defaultStorageLimit.DeepCopy()
+		storage.Request, storage.Limit = &request, &limit
+	case storage.Request == nil:
+		request := storage.Limit.DeepCopy()
+		storage.Request = &request
+	case storage.Limit == nil:
+		limit := storage.Request.DeepCopy()
+		storage.Limit = &limit
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+func TestWithDefaultsEmptySpec(t *testing.T) {
+	mysqlObj := &mysqlalpha1.MySQL{}
+	mysqlObj.Namespace, mysqlObj.Name = "ns", "db"
+	ret := withDefaults(mysqlObj)
+
+	if !equality.Semantic.DeepEqual(mysqlObj.Spec, mysqlalpha1.MySQLSpec{}) {
+		t.Errorf("withDefaults modified its argument: %+v", mysqlObj.Spec)
+	}
+	spec := ret.Spec
+	if spec.Replicas == nil || *spec.Replicas != replicas {
+		t.Errorf("replicas = %v, want %d", spec.Replicas, replicas)
+	}
+	if spec.Flavor != mysqlalpha1.FlavorMySQL || spec.DeletionPolicy != mysqlalpha1.DeletionPolicyDelete {
+		t.Errorf("flavor = %q, deletionPolicy = %q", spec.Flavor, spec.DeletionPolicy)
+	}
+	if spec.Port != defaultPort || spec.DataDir != volumeMoutPath {
+		t.Errorf("port = %d, dataDir = %q", spec.Port, spec.DataDir)
+	}
+	if spec.Storage.Request == nil || spec.Storage.Limit == nil {
+		t.Errorf("storage = %+v, want the default sizes", spec.Storage)
+	}
+	if spec.Backup.HistoryLimit.Successful == nil || spec.Backup.HistoryLimit.Failed == nil {
+		t.Errorf("backup history limits are not defaulted")
+	}
+	for name, probe := range map[string]mysqlalpha1.MySQLProbeSpec{
+		"liveness":  spec.Probes.Liveness,
+		"readiness": spec.Probes.Readiness,
+		"startup":   spec.Probes.Startup,
+	} {
+		if probe.Mode == "" || probe.PeriodSeconds == 0 || probe.TimeoutSeconds == 0 || probe.FailureThreshold == 0 {
+			t.Errorf("%s probe = %+v, want defaults", name, probe)
+		}
+	}
+	// Optional pointers keep their meaning.
+	if spec.BinlogStorage != nil || spec.NetworkPolicy != nil {
+		t.Errorf("binlogStorage = %v, networkPolicy = %v, want nil", spec.BinlogStorage, spec.NetworkPolicy)
+	}
+	if again := withDefaults(ret); !equality.Semantic.DeepEqual(again.Spec, spec) {
+		t.Errorf("withDefaults is not idempotent")
+	}
+
+	// The children are built from the defaulted spec without dereferencing a
+	// nil field.
+	newStatefulSet(ret, ret.Name+"-deployment", "")
+	newClientService(ret)
+	newBackupCronJob(ret)
+	renderConfig(ret)
+}
+
+func TestWithDefaultsKeepsSetFields(t *testing.T) {
+	limit := resource.MustParse("50Gi")
+	desired := int32(3)
+	mysqlObj := &mysqlalpha1.MySQL{}
+	mysqlObj.Spec.Replicas = &desired
+	mysqlObj.Spec.Port = 3307
+	mysqlObj.Spec.Storage.Limit = &limit
+	mysqlObj.Spec.Probes.Startup.FailureThreshold = 60
+	ret := withDefaults(mysqlObj)
+
+	if *ret.Spec.Replicas != 3 || ret.Spec.Port != 3307 {
+		t.Errorf("replicas = %d, port = %d, want 3 and 3307", *ret.Spec.Replicas, ret.Spec.Port)
+	}
+	if ret.Spec.Storage.Request.Cmp(limit) != 0 {
+		t.Errorf("storage request = %v, want the limit %v", ret.Spec.Storage.Request, limit)
+	}
+	if startup := ret.Spec.Probes.Startup; startup.FailureThreshold != 60 || startup.PeriodSeconds != startupProbeDefaults.PeriodSeconds {
+		t.Errorf("startup probe = %+v, want 60 failures and the default period", startup)
+	}
+}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	crscheme "github.com/cyhw/mysql-operator/pkg/clients/clientset/versioned/scheme"
+)
+
+const eventSource = "mysql-operator"
+
+// newEventRecorder returns a recorder of Events on the MySQLs. The broadcaster
+// aggregates repeated Events and writes them in the background, a failure to
+// write one is only logged.
+func newEventRecorder(k8sClient kubernetes.Interface) (record.EventBroadcaster, record.EventRecorder) {
+	// The recorder looks the kind of the MySQL up in the scheme.
+	utilruntime.Must(crscheme.AddToScheme(scheme.Scheme))
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartStructuredLogging(0)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: k8sClient.CoreV1().Events("")})
+	return broadcaster, broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventSource})
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+func TestEventRecorder(t *testing.T) {
+	k8sClient := fake.NewSimpleClientset()
+	broadcaster, recorder := newEventRecorder(k8sClient)
+	defer broadcaster.Shutdown()
+
+	mysqlObj := &mysqlalpha1.MySQL{}
+	mysqlObj.Namespace, mysqlObj.Name = "ns", "db"
+	recorder.Eventf(mysqlObj, corev1.EventTypeNormal, reasonAutoMinorUpgrade, "Upgrading from version %s to %s", "8.0.30", "8.0.31")
+
+	var events *corev1.EventList
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		var err error
+		events, err = k8sClient.CoreV1().Events("ns").List(context.Background(), metav1.ListOptions{})
+		return err == nil && len(events.Items) > 0, err
+	})
+	if err != nil {
+		t.Fatalf("event not recorded: %v", err)
+	}
+	event := events.Items[0]
+	if event.InvolvedObject.Kind != "MySQL" || event.InvolvedObject.Name != "db" || event.Source.Component != eventSource {
+		t.Errorf("event = %+v, want one on the MySQL from %s", event, eventSource)
+	}
+	if event.Message != "Upgrading from version 8.0.30 to 8.0.31" {
+		t.Errorf("event message = %q", event.Message)
+	}
+}
@@ -0,0 +1,41 @@
+package controller
+
+import "context"
+
+// Exported for the tests of controller_test, which drive the Controller built
+// by the fixture.
+
+// QueueLen returns the number of keys waiting in the queue.
+func (c *Controller) QueueLen() int {
+	return c.queue.Len()
+}
+
+// NextKey pops the next key of the queue.
+func (c *Controller) NextKey() string {
+	key, _ := c.queue.Get()
+	c.queue.Done(key)
+	return key.(string)
+}
+
+// Update and Delete are the event handlers of the MySQL informer.
+func (c *Controller) Update(old, new interface{}) {
+	c.update(old, new)
+}
+
+func (c *Controller) Delete(obj interface{}) {
+	c.delete(obj)
+}
+
+// LockKey locks the key the way a running reconcile does, and returns the
+// function unlocking it.
+func (c *Controller) LockKey(key string) func() {
+	c.keyLocks.Lock(key)
+	return func() {
+		c.keyLocks.Unlock(key)
+	}
+}
+
+// CheckPermissions runs the permission checks done at startup.
+func (c *Controller) CheckPermissions(ctx context.Context) {
+	c.checkPermissions(ctx)
+}
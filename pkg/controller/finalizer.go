@@ -0,0 +1,297 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	backupPollInterval      = 10 * time.Second
+	statefulSetPollInterval = 5 * time.Second
+)
+
+// finalize runs the deletion policy of a MySQL that is being deleted and
+// releases the finalizer once its children are gone.
+func (c *Controller) finalize(mysqlObj *mysqlalpha1.MySQL) error {
+	if !containsString(mysqlObj.Finalizers, finalizerName) {
+		return nil
+	}
+	if err := c.labelClaims(mysqlObj); err != nil {
+		return err
+	}
+
+	if mysqlObj.Spec.DeletionPolicy == mysqlalpha1.DeletionPolicySnapshot {
+		done, err := c.finalBackup(mysqlObj)
+		if err != nil {
+			return err
+		}
+		if !done {
+			klog.InfoS("Wait for final backup.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name)
+			c.enqueueAfter(mysqlObj, backupPollInterval)
+			return nil
+		}
+	}
+
+	if mysqlObj.Spec.DeletionPolicy == mysqlalpha1.DeletionPolicyRetain {
+		// The claims are released first, the retention policy of the
+		// StatefulSets may otherwise have them collected with the pods.
+		if err := c.releaseClaims(mysqlObj); err != nil {
+			return err
+		}
+		c.cleanup(mysqlObj)
+	} else {
+		c.cleanup(mysqlObj)
+		// The StatefulSet controller recreates missing claims of running pods,
+		// so the claims are only removed once the StatefulSet is gone.
+		for _, name := range statefulSetNames(mysqlObj) {
+			_, err := c.k8sClient.AppsV1().StatefulSets(mysqlObj.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err == nil {
+				klog.InfoS("Wait for statefulset deletion.", "namespace", mysqlObj.Namespace, "name", name)
+				c.enqueueAfter(mysqlObj, statefulSetPollInterval)
+				return nil
+			}
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+		if err := c.deleteClaims(mysqlObj); err != nil {
+			return err
+		}
+	}
+	if mysqlObj.Spec.DeletionPolicy == mysqlalpha1.DeletionPolicySnapshot {
+		propagation := metav1.DeletePropagationBackground
+		err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Delete(context.Background(), finalBackupJobName(mysqlObj), metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	ret := mysqlObj.DeepCopy()
+	ret.Finalizers = removeString(ret.Finalizers, finalizerName)
+	_, err := c.crClient.VolcV1alpha1().MySQLs(ret.Namespace).Update(context.TODO(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Remove finalizer.", "namespace", ret.Namespace, "name", ret.Name)
+	return nil
+}
+
+// cleanup deletes the children of a MySQL, ignoring the ones already gone.
+// The password Secret and the headless Service are shared by the MySQLs of the
+// namespace, and only deleted with the last of them.
+func (c *Controller) cleanup(mysqlObj *mysqlalpha1.MySQL) {
+	if c.lastInNamespace(mysqlObj) {
+		_ = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Delete(context.Background(), secretName, metav1.DeleteOptions{})
+		_ = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Delete(context.Background(), headlessServiceName(mysqlObj), metav1.DeleteOptions{})
+	}
+	_ = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Delete(context.Background(), clientServiceName(mysqlObj), metav1.DeleteOptions{})
+	for _, name := range statefulSetNames(mysqlObj) {
+		_ = c.k8sClient.AppsV1().StatefulSets(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+	}
+	propagation := metav1.DeletePropagationBackground
+	_ = c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Delete(context.Background(), promoteJobName(mysqlObj), metav1.DeleteOptions{PropagationPolicy: &propagation})
+	_ = c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Delete(context.Background(), upgradeBackupJobName(mysqlObj), metav1.DeleteOptions{PropagationPolicy: &propagation})
+	_ = c.k8sClient.BatchV1().CronJobs(mysqlObj.Namespace).Delete(context.Background(), backupCronJobName(mysqlObj), metav1.DeleteOptions{})
+	_ = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Delete(context.Background(), connectionSecretName(mysqlObj), metav1.DeleteOptions{})
+	_ = c.k8sClient.CoreV1().ConfigMaps(mysqlObj.Namespace).Delete(context.Background(), configMapName(mysqlObj), metav1.DeleteOptions{})
+	_ = c.k8sClient.CoreV1().ConfigMaps(mysqlObj.Namespace).Delete(context.Background(), startupScriptName(mysqlObj), metav1.DeleteOptions{})
+	_ = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Delete(context.Background(), metricsServiceName(mysqlObj), metav1.DeleteOptions{})
+	_ = c.dynamicClient.Resource(serviceMonitorResource).Namespace(mysqlObj.Namespace).Delete(context.Background(), metricsServiceName(mysqlObj), metav1.DeleteOptions{})
+	_ = c.dynamicClient.Resource(podGroupResource).Namespace(mysqlObj.Namespace).Delete(context.Background(), podGroupName(mysqlObj), metav1.DeleteOptions{})
+	_ = c.k8sClient.NetworkingV1().NetworkPolicies(mysqlObj.Namespace).Delete(context.Background(), networkPolicyName(mysqlObj), metav1.DeleteOptions{})
+	_ = c.deleteProxy(mysqlObj)
+	c.topologyWarned.Delete(mysqlObj.Namespace + "/" + mysqlObj.Name)
+}
+
+// lastInNamespace reports whether no other MySQL of the namespace, being
+// deleted or not, still uses the shared children. A failed lookup keeps them.
+func (c *Controller) lastInNamespace(mysqlObj *mysqlalpha1.MySQL) bool {
+	mysqlObjs, err := c.crLister.MySQLs(mysqlObj.Namespace).List(labels.Everything())
+	if err != nil {
+		return false
+	}
+	for _, other := range mysqlObjs {
+		if other.Name != mysqlObj.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// releaseClaims drops the owner references of the data PVCs so they outlive
+// the MySQL.
+func (c *Controller) releaseClaims(mysqlObj *mysqlalpha1.MySQL) error {
+	claims, err := c.listClaims(mysqlObj)
+	if err != nil {
+		return err
+	}
+
+	for i := range claims {
+		claim := &claims[i]
+		if len(claim.OwnerReferences) == 0 {
+			continue
+		}
+		claim.OwnerReferences = nil
+		_, err = c.k8sClient.CoreV1().PersistentVolumeClaims(claim.Namespace).Update(context.Background(), claim, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Retain PVC.", "namespace", claim.Namespace, "name", claim.Name)
+	}
+	return nil
+}
+
+// deleteClaims deletes the data PVCs created from the volume claim templates.
+func (c *Controller) deleteClaims(mysqlObj *mysqlalpha1.MySQL) error {
+	claims, err := c.listClaims(mysqlObj)
+	if err != nil {
+		return err
+	}
+
+	for _, claim := range claims {
+		err = c.k8sClient.CoreV1().PersistentVolumeClaims(claim.Namespace).Delete(context.Background(), claim.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete PVC.", "namespace", claim.Namespace, "name", claim.Name)
+	}
+	return nil
+}
+
+// listClaims returns the PVCs the StatefulSets created for the MySQL.
+func (c *Controller) listClaims(mysqlObj *mysqlalpha1.MySQL) ([]corev1.PersistentVolumeClaim, error) {
+	claims, err := c.k8sClient.CoreV1().PersistentVolumeClaims(mysqlObj.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: SelectorForInstance(mysqlObj.Name).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []corev1.PersistentVolumeClaim
+	for _, claim := range claims.Items {
+		if isClaimOfMySQL(mysqlObj, claim.Name) {
+			ret = append(ret, claim)
+		}
+	}
+	return ret, nil
+}
+
+// isClaimOfMySQL reports whether the named PVC was created for a pod of one of
+// the StatefulSets of the MySQL. A prefix match would also take the claims of
+// a MySQL named like "<name>-deployment-x".
+func isClaimOfMySQL(mysqlObj *mysqlalpha1.MySQL, claim string) bool {
+	for _, sts := range statefulSetNames(mysqlObj) {
+		if isClaimOfStatefulSet(claim, sts) {
+			return true
+		}
+	}
+	return false
+}
+
+// labelClaims adds the instance label to the claims of the MySQL that lack it.
+// The volume claim templates of a StatefulSet are immutable, so the sets
+// created before the templates carried the label keep creating claims
+// without it.
+func (c *Controller) labelClaims(mysqlObj *mysqlalpha1.MySQL) error {
+	claims, err := c.claimLister.PersistentVolumeClaims(mysqlObj.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, claim := range claims {
+		if _, ok := claim.Labels[instanceLabelKey]; ok || !isClaimOfMySQL(mysqlObj, claim.Name) {
+			continue
+		}
+		ret := claim.DeepCopy()
+		if ret.Labels == nil {
+			ret.Labels = map[string]string{}
+		}
+		ret.Labels[instanceLabelKey] = mysqlObj.Name
+		_, err = c.k8sClient.CoreV1().PersistentVolumeClaims(ret.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Label PVC.", "namespace", ret.Namespace, "name", ret.Name)
+	}
+	return nil
+}
+
+// finalBackup makes sure the final backup Job exists and reports whether it
+// has completed.
+func (c *Controller) finalBackup(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	if mysqlObj.Spec.Backup.ClaimName == "" {
+		return false, fmt.Errorf("deletion policy %s requires spec.backup.claimName", mysqlalpha1.DeletionPolicySnapshot)
+	}
+
+	name := finalBackupJobName(mysqlObj)
+	job, err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Create(context.Background(), newFinalBackupJob(mysqlObj), metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		klog.InfoS("Create final backup job.", "namespace", mysqlObj.Namespace, "name", name)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			return false, fmt.Errorf("final backup job %s failed: %s", name, cond.Message)
+		}
+	}
+	return false, nil
+}
+
+func finalBackupJobName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-final-backup"
+}
+
+func newFinalBackupJob(mysqlObj *mysqlalpha1.MySQL) *batchv1.Job {
+	dump := fmt.Sprintf("%s -h %s%s -uroot -p\"$%s\" --all-databases > %s/%s-final.sql",
+		flavorOf(mysqlObj).dump, clientServiceHost(mysqlObj), portArg(mysqlObj), flavorOf(mysqlObj).passwordEnv, backupMountPath, mysqlObj.Name)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      finalBackupJobName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+		},
+		Spec: newBackupJobSpec(mysqlObj, dump),
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, s string) []string {
+	var ret []string
+	for _, item := range list {
+		if item != s {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,160 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+	"github.com/cyhw/mysql-operator/pkg/controller"
+	ctrltesting "github.com/cyhw/mysql-operator/pkg/controller/testing"
+)
+
+// newDeletedMySQL returns a MySQL being deleted, with its StatefulSet and the
+// claim of its first pod.
+func newDeletedMySQL(policy mysqlalpha1.DeletionPolicy) (*mysqlalpha1.MySQL, []runtime.Object) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	mysqlObj.Spec.DeletionPolicy = policy
+	mysqlObj.Finalizers = []string{"volc.bytedance.com/cleanup"}
+	now := metav1.Now()
+	mysqlObj.DeletionTimestamp = &now
+
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "db-deployment"},
+	}
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "mysql-store-db-deployment-0",
+			Labels:    controller.LabelsForInstance("db"),
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db-deployment", UID: "uid-sts"},
+			},
+		},
+	}
+	return mysqlObj, []runtime.Object{sts, claim}
+}
+
+func TestFinalizeRetainKeepsClaims(t *testing.T) {
+	mysqlObj, kubeObjects := newDeletedMySQL(mysqlalpha1.DeletionPolicyRetain)
+	f := ctrltesting.NewFixture(kubeObjects, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	claim, err := f.K8sClient.CoreV1().PersistentVolumeClaims("ns").Get(context.Background(), "mysql-store-db-deployment-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("retained claim: %v", err)
+	}
+	if len(claim.OwnerReferences) != 0 {
+		t.Errorf("retained claim owner references = %v, want none", claim.OwnerReferences)
+	}
+	if deleted := f.Deleted("persistentvolumeclaims"); len(deleted) != 0 {
+		t.Errorf("deleted claims %v under Retain", deleted)
+	}
+
+	// The claim is released before its StatefulSet is deleted.
+	released, deletedSts := -1, -1
+	for i, action := range f.K8sClient.Actions() {
+		switch {
+		case action.GetVerb() == "update" && action.GetResource().Resource == "persistentvolumeclaims":
+			released = i
+		case action.GetVerb() == "delete" && action.GetResource().Resource == "statefulsets" && deletedSts < 0:
+			deletedSts = i
+		}
+	}
+	if released < 0 || deletedSts < 0 || released > deletedSts {
+		t.Errorf("claim released at action %d, statefulset deleted at action %d", released, deletedSts)
+	}
+	assertFinalizerRemoved(t, f)
+}
+
+func TestFinalizeDeleteRemovesClaims(t *testing.T) {
+	mysqlObj, kubeObjects := newDeletedMySQL(mysqlalpha1.DeletionPolicyDelete)
+	f := ctrltesting.NewFixture(kubeObjects, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	_, err := f.K8sClient.CoreV1().PersistentVolumeClaims("ns").Get(context.Background(), "mysql-store-db-deployment-0", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("claim after deletion: %v, want NotFound", err)
+	}
+	assertFinalizerRemoved(t, f)
+}
+
+func TestFinalizeDeleteKeepsClaimsOfOtherInstances(t *testing.T) {
+	mysqlObj, kubeObjects := newDeletedMySQL(mysqlalpha1.DeletionPolicyDelete)
+	// The StatefulSet of "db-deployment-x" is named like the claims of "db".
+	other := ctrltesting.NewMySQL("ns", "db-deployment-x", "8.0")
+	other.Spec.DeletionPolicy = mysqlalpha1.DeletionPolicyDelete
+	newClaim := func(name string, labels map[string]string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Labels: labels},
+		}
+	}
+	// Claims of sets created before the templates carried the instance label.
+	legacy := map[string]string{"app": "mysql"}
+	kubeObjects = append(kubeObjects,
+		newClaim("mysql-store-db-deployment-1", legacy),
+		newClaim("mysql-store-db-deployment-x-deployment-0", controller.LabelsForInstance("db-deployment-x")),
+		newClaim("mysql-store-db-deployment-x-deployment-1", legacy),
+	)
+	f := ctrltesting.NewFixture(kubeObjects, []runtime.Object{mysqlObj, other}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	deleted := map[string]bool{}
+	for _, name := range f.Deleted("persistentvolumeclaims") {
+		deleted[name] = true
+	}
+	if len(deleted) != 2 || !deleted["mysql-store-db-deployment-0"] || !deleted["mysql-store-db-deployment-1"] {
+		t.Errorf("deleted claims %v, want only the claims of db", f.Deleted("persistentvolumeclaims"))
+	}
+	for _, name := range []string{"mysql-store-db-deployment-x-deployment-0", "mysql-store-db-deployment-x-deployment-1"} {
+		if _, err := f.K8sClient.CoreV1().PersistentVolumeClaims("ns").Get(context.Background(), name, metav1.GetOptions{}); err != nil {
+			t.Errorf("claim %s of db-deployment-x: %v", name, err)
+		}
+	}
+}
+
+func TestFinalizeDeleteWaitsForStatefulSet(t *testing.T) {
+	mysqlObj, kubeObjects := newDeletedMySQL(mysqlalpha1.DeletionPolicyDelete)
+	f := ctrltesting.NewFixture(kubeObjects, []runtime.Object{mysqlObj}, controller.Options{})
+	// The StatefulSet lingers while its pods terminate.
+	f.K8sClient.PrependReactor("delete", "statefulsets", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	if deleted := f.Deleted("persistentvolumeclaims"); len(deleted) != 0 {
+		t.Errorf("deleted claims %v while the statefulset exists", deleted)
+	}
+	got, err := f.CRClient.VolcV1alpha1().MySQLs("ns").Get(context.Background(), "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Finalizers) == 0 {
+		t.Errorf("finalizer removed while the statefulset exists")
+	}
+}
+
+func assertFinalizerRemoved(t *testing.T, f *ctrltesting.Fixture) {
+	t.Helper()
+	got, err := f.CRClient.VolcV1alpha1().MySQLs("ns").Get(context.Background(), "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Finalizers) != 0 {
+		t.Errorf("finalizers = %v, want none", got.Finalizers)
+	}
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// flavor describes what differs between the images of the MySQL-compatible
+// servers.
+type flavor struct {
+	// repository is the default image repository, tagged with spec.version.
+	repository string
+	// entrypoint is the entrypoint of the image, which initializes the data
+	// directory before starting the server.
+	entrypoint string
+	// passwordEnv and databaseEnv are read by the image entrypoint when it
+	// initializes the data directory.
+	passwordEnv string
+	databaseEnv string
+	// server is the server binary and client, dump and admin the command
+	// line tools of the image.
+	server string
+	client string
+	dump   string
+	admin  string
+	// auditLog configures the audit log plugin to write to the file.
+	auditLog func(file string) []string
+	// superReadOnly is set when the server has super_read_only, which
+	// extends read_only to the users with the SUPER privilege.
+	superReadOnly bool
+}
+
+var flavors = map[mysqlalpha1.Flavor]flavor{
+	mysqlalpha1.FlavorMySQL: {
+		repository:    "arm64v8/mysql",
+		entrypoint:    "docker-entrypoint.sh",
+		passwordEnv:   "MYSQL_ROOT_PASSWORD",
+		databaseEnv:   "MYSQL_DATABASE",
+		server:        "mysqld",
+		client:        "mysql",
+		dump:          "mysqldump",
+		admin:         "mysqladmin",
+		auditLog:      auditLogPlugin,
+		superReadOnly: true,
+	},
+	// MariaDB 11 images no longer ship the mysql* binaries.
+	mysqlalpha1.FlavorMariaDB: {
+		repository:  "mariadb",
+		entrypoint:  "docker-entrypoint.sh",
+		passwordEnv: "MARIADB_ROOT_PASSWORD",
+		databaseEnv: "MARIADB_DATABASE",
+		server:      "mariadbd",
+		client:      "mariadb",
+		dump:        "mariadb-dump",
+		admin:       "mariadb-admin",
+		auditLog: func(file string) []string {
+			return []string{
+				"plugin_load_add = server_audit",
+				"server_audit = FORCE_PLUS_PERMANENT",
+				"server_audit_logging = ON",
+				"server_audit_output_type = file",
+				"server_audit_file_path = " + file,
+			}
+		},
+	},
+	mysqlalpha1.FlavorPercona: {
+		repository:    "percona/percona-server",
+		entrypoint:    "/docker-entrypoint.sh",
+		passwordEnv:   "MYSQL_ROOT_PASSWORD",
+		databaseEnv:   "MYSQL_DATABASE",
+		server:        "mysqld",
+		client:        "mysql",
+		dump:          "mysqldump",
+		admin:         "mysqladmin",
+		auditLog:      auditLogPlugin,
+		superReadOnly: true,
+	},
+}
+
+// auditLogPlugin configures the audit_log plugin of MySQL Enterprise and
+// Percona Server. The server refuses to start if it cannot be loaded.
+func auditLogPlugin(file string) []string {
+	return []string{
+		"plugin_load_add = audit_log.so",
+		"audit_log = FORCE_PLUS_PERMANENT",
+		"audit_log_format = JSON",
+		"audit_log_file = " + file,
+	}
+}
+
+// flavorOf returns the flavor of a MySQL, mysql when unset.
+func flavorOf(mysqlObj *mysqlalpha1.MySQL) flavor {
+	if f, ok := flavors[mysqlObj.Spec.Flavor]; ok {
+		return f
+	}
+	return flavors[mysqlalpha1.FlavorMySQL]
+}
@@ -0,0 +1,103 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+	"github.com/cyhw/mysql-operator/pkg/controller"
+	ctrltesting "github.com/cyhw/mysql-operator/pkg/controller/testing"
+)
+
+func TestUpdateIgnoresStatusWrites(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		mutate  func(*mysqlalpha1.MySQL)
+		enqueue bool
+	}{
+		{
+			name: "status",
+			mutate: func(mysqlObj *mysqlalpha1.MySQL) {
+				mysqlObj.ResourceVersion = "2"
+				mysqlObj.Status.Message = "Ready"
+				mysqlObj.Status.ObservedGeneration = 1
+			},
+		},
+		{
+			name: "spec",
+			mutate: func(mysqlObj *mysqlalpha1.MySQL) {
+				mysqlObj.Generation++
+				mysqlObj.Spec.Version = "8.1"
+			},
+			enqueue: true,
+		},
+		{
+			name: "annotations",
+			mutate: func(mysqlObj *mysqlalpha1.MySQL) {
+				mysqlObj.Annotations = map[string]string{"example.com/key": "value"}
+			},
+			enqueue: true,
+		},
+		{
+			name: "deletion",
+			mutate: func(mysqlObj *mysqlalpha1.MySQL) {
+				now := metav1.Now()
+				mysqlObj.DeletionTimestamp = &now
+			},
+			enqueue: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			old := ctrltesting.NewMySQL("ns", "db", "8.0")
+			f := ctrltesting.NewFixture(nil, []runtime.Object{old}, controller.Options{})
+			updated := old.DeepCopy()
+			tc.mutate(updated)
+			f.Controller.Update(old, updated)
+			if got := f.Controller.QueueLen() == 1; got != tc.enqueue {
+				t.Errorf("enqueued = %v, want %v", got, tc.enqueue)
+			}
+		})
+	}
+}
+
+func TestDeleteTombstoneCleansUp(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	// The finalizer was removed by hand and the final state of the MySQL was
+	// missed by the informer.
+	err := f.CRClient.VolcV1alpha1().MySQLs("ns").Delete(context.Background(), "db", metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Controller.Delete(cache.DeletedFinalStateUnknown{Key: "ns/db", Obj: mysqlObj})
+	if n := f.Controller.QueueLen(); n != 1 {
+		t.Fatalf("queue length = %d, want 1", n)
+	}
+	key := f.Controller.NextKey()
+	if key != "ns/db" {
+		t.Fatalf("enqueued key = %q, want ns/db", key)
+	}
+
+	f.K8sClient.ClearActions()
+	if err := f.SyncCaches(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Controller.Reconcile(context.Background(), key); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	deleted := map[string]bool{}
+	for _, name := range f.Deleted("statefulsets") {
+		deleted[name] = true
+	}
+	if !deleted["db-deployment"] {
+		t.Errorf("deleted statefulsets %v, want db-deployment", f.Deleted("statefulsets"))
+	}
+}
@@ -0,0 +1,46 @@
+package controller
+
+import "sync"
+
+// keyMutex serializes the work on each key while letting different keys
+// proceed concurrently.
+type keyMutex struct {
+	lock  sync.Mutex
+	locks map[string]*refMutex
+}
+
+type refMutex struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyMutex() *keyMutex {
+	return &keyMutex{locks: map[string]*refMutex{}}
+}
+
+// Lock locks the key, waiting until it is unlocked.
+func (m *keyMutex) Lock(key string) {
+	m.lock.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &refMutex{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.lock.Unlock()
+
+	l.Lock()
+}
+
+// Unlock unlocks the key, forgetting it once nobody waits for it.
+func (m *keyMutex) Unlock(key string) {
+	m.lock.Lock()
+	l := m.locks[key]
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+	m.lock.Unlock()
+
+	l.Unlock()
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyMutexSerializesKey(t *testing.T) {
+	m := newKeyMutex()
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Lock("ns/db")
+			defer m.Unlock("ns/db")
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	if maxRunning != 1 {
+		t.Errorf("%d holders of the key at once, want 1", maxRunning)
+	}
+	if len(m.locks) != 0 {
+		t.Errorf("%d keys left locked, want none", len(m.locks))
+	}
+}
+
+func TestKeyMutexLetsOtherKeysRun(t *testing.T) {
+	m := newKeyMutex()
+	m.Lock("ns/a")
+	done := make(chan struct{})
+	go func() {
+		m.Lock("ns/b")
+		m.Unlock("ns/b")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a locked key blocks another key")
+	}
+	m.Unlock("ns/a")
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"github.com/cyhw/mysql-operator/pkg/metrics"
+)
+
+const (
+	phaseSecret           = "secret"
+	phaseRestore          = "restore"
+	phaseConfig           = "config"
+	phaseStartupScript    = "startup_script"
+	phaseServices         = "services"
+	phasePodGroup         = "pod_group"
+	phaseStatefulSet      = "statefulset"
+	phaseBackup           = "backup"
+	phaseSnapshot         = "snapshot"
+	phaseOrphans          = "orphans"
+	phaseConnectionSecret = "connection_secret"
+	phaseNetworkPolicy    = "network_policy"
+	phaseMonitoring       = "monitoring"
+	phaseProxy            = "proxy"
+	phaseOnDemandBackup   = "on_demand_backup"
+	phaseBackupJobs       = "backup_jobs"
+	phaseStatus           = "status"
+	phaseResources        = "resources"
+	phaseAvailable        = "available"
+)
+
+var phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "mysql_operator_reconcile_phase_duration_seconds",
+	Help:    "Time taken by each phase of a MySQL reconcile.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"phase"})
+
+func init() {
+	metrics.MustRegister(phaseDuration)
+}
+
+func observePhase(phase string, start time.Time) {
+	phaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+var (
+	instanceReadyReplicasDesc = prometheus.NewDesc("mysql_instance_ready_replicas",
+		"Ready pods of each MySQL.", []string{"namespace", "name"}, nil)
+	instanceDesiredReplicasDesc = prometheus.NewDesc("mysql_instance_desired_replicas",
+		"Pods each MySQL is expected to run.", []string{"namespace", "name"}, nil)
+)
+
+// instanceCollector exposes the replicas of every MySQL, read from the
+// listers on each scrape. The series of a deleted MySQL disappear with it.
+type instanceCollector struct {
+	c *Controller
+}
+
+// InstanceCollector returns the collector of the per-instance metrics.
+func (c *Controller) InstanceCollector() prometheus.Collector {
+	return instanceCollector{c: c}
+}
+
+// Describe implements prometheus.Collector.
+func (ic instanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- instanceReadyReplicasDesc
+	ch <- instanceDesiredReplicasDesc
+}
+
+// Collect implements prometheus.Collector.
+func (ic instanceCollector) Collect(ch chan<- prometheus.Metric) {
+	mysqlObjs, err := ic.c.crLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Failed to list MySQLs for metrics")
+		return
+	}
+
+	for _, mysqlObj := range mysqlObjs {
+		mysqlObj = withDefaults(mysqlObj)
+		var ready int32
+		sts, err := ic.c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(statefulSetName(mysqlObj))
+		if err == nil {
+			ready = sts.Status.ReadyReplicas
+		} else if !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "Failed to get statefulset for metrics", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(instanceReadyReplicasDesc, prometheus.GaugeValue, float64(ready), mysqlObj.Namespace, mysqlObj.Name)
+		ch <- prometheus.MustNewConstMetric(instanceDesiredReplicasDesc, prometheus.GaugeValue, float64(desiredReplicas(mysqlObj)), mysqlObj.Namespace, mysqlObj.Name)
+	}
+}
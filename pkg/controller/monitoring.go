@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	exporterContainerName      = "exporter"
+	exporterImage              = "prom/mysqld-exporter:v0.15.1"
+	exporterPasswordEnvName    = "MYSQLD_EXPORTER_PASSWORD"
+	exporterPort               = int32(9104)
+	exporterPortName           = "metrics"
+	serviceMonitorGroupVersion = schema.GroupVersion{Group: "monitoring.coreos.com", Version: "v1"}
+	serviceMonitorResource     = serviceMonitorGroupVersion.WithResource("servicemonitors")
+)
+
+// syncMonitoring converges the Service exposing the exporter sidecar and the
+// ServiceMonitor scraping it. The ServiceMonitor is skipped when the
+// Prometheus Operator is not installed.
+func (c *Controller) syncMonitoring(mysqlObj *mysqlalpha1.MySQL) error {
+	if err := c.syncMetricsService(mysqlObj); err != nil {
+		return err
+	}
+
+	available, err := c.resourceAvailable(serviceMonitorResource)
+	if err != nil {
+		return err
+	}
+	if !available {
+		if mysqlObj.Spec.Metrics.ServiceMonitor {
+			klog.InfoS("ServiceMonitor is not available, skip service monitor.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name)
+		}
+		return nil
+	}
+
+	name := metricsServiceName(mysqlObj)
+	client := c.dynamicClient.Resource(serviceMonitorResource).Namespace(mysqlObj.Namespace)
+	current, err := client.Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+
+	if !mysqlObj.Spec.Metrics.Enabled || !mysqlObj.Spec.Metrics.ServiceMonitor {
+		if current == nil {
+			return nil
+		}
+		err = client.Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete service monitor.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+
+	desired := newServiceMonitor(mysqlObj)
+	if current == nil {
+		_, err = client.Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create service monitor.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if current.GetAnnotations()[specHashAnnotation] == desired.GetAnnotations()[specHashAnnotation] {
+		return nil
+	}
+	desired.SetResourceVersion(current.GetResourceVersion())
+	_, err = client.Update(context.Background(), desired, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update service monitor.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// syncMetricsService converges the Service of the exporter sidecar, or removes
+// it when the exporter is disabled.
+func (c *Controller) syncMetricsService(mysqlObj *mysqlalpha1.MySQL) error {
+	name := metricsServiceName(mysqlObj)
+	current, err := c.serviceLister.Services(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+
+	if !mysqlObj.Spec.Metrics.Enabled {
+		if current == nil {
+			return nil
+		}
+		err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete metrics service.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+
+	desired := newMetricsService(mysqlObj)
+	if current == nil {
+		_, err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create metrics service.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if metricsServiceMatches(current, desired) {
+		return nil
+	}
+	ret := current.DeepCopy()
+	ret.Labels = desired.Labels
+	ret.Spec.Ports = desired.Spec.Ports
+	ret.Spec.Selector = desired.Spec.Selector
+	_, err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update metrics service.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// monitoringUpToDate reports whether the metrics Service and the
+// ServiceMonitor match the spec, so deleted or edited ones are converged
+// again. The ServiceMonitor is not cached, it is read only when requested.
+func (c *Controller) monitoringUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	current, err := c.serviceLister.Services(mysqlObj.Namespace).Get(metricsServiceName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return false, err
+	}
+	if !mysqlObj.Spec.Metrics.Enabled {
+		return current == nil, nil
+	}
+	if current == nil || !metricsServiceMatches(current, newMetricsService(mysqlObj)) {
+		return false, nil
+	}
+	if !mysqlObj.Spec.Metrics.ServiceMonitor {
+		return true, nil
+	}
+
+	available, err := c.resourceAvailable(serviceMonitorResource)
+	if err != nil || !available {
+		return !available, err
+	}
+	serviceMonitor, err := c.dynamicClient.Resource(serviceMonitorResource).Namespace(mysqlObj.Namespace).Get(context.Background(), metricsServiceName(mysqlObj), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return serviceMonitor.GetAnnotations()[specHashAnnotation] == newServiceMonitor(mysqlObj).GetAnnotations()[specHashAnnotation], nil
+}
+
+func newMetricsService(mysqlObj *mysqlalpha1.MySQL) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      metricsServiceName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+			Labels:    metricsServiceLabels(mysqlObj),
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       exporterPortName,
+					Protocol:   corev1.ProtocolTCP,
+					Port:       exporterPort,
+					TargetPort: intstr.FromString(exporterPortName),
+				},
+			},
+			Selector: LabelsForInstance(mysqlObj.Name),
+		},
+	}
+}
+
+// metricsServiceMatches reports whether the fields of the metrics Service set
+// by the controller are the desired ones.
+func metricsServiceMatches(current, desired *corev1.Service) bool {
+	return equality.Semantic.DeepEqual(current.Labels, desired.Labels) &&
+		equality.Semantic.DeepEqual(current.Spec.Ports, desired.Spec.Ports) &&
+		equality.Semantic.DeepEqual(current.Spec.Selector, desired.Spec.Selector)
+}
+
+func metricsServiceName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-metrics"
+}
+
+func metricsServiceLabels(mysqlObj *mysqlalpha1.MySQL) map[string]string {
+	return LabelsForInstance(mysqlObj.Name)
+}
+
+// exporterContainer returns the mysqld-exporter sidecar, which connects to
+// MySQL as root over the pod network namespace.
+func exporterContainer(mysqlObj *mysqlalpha1.MySQL) corev1.Container {
+	env := rootPasswordEnv(mysqlObj)
+	env.Name = exporterPasswordEnvName
+	return corev1.Container{
+		Name:  exporterContainerName,
+		Image: exporterImage,
+		Args: []string{
+			"--mysqld.username=" + rootUser,
+			fmt.Sprintf("--mysqld.address=127.0.0.1:%d", mysqlPort(mysqlObj)),
+		},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          exporterPortName,
+				ContainerPort: exporterPort,
+			},
+		},
+		Env: []corev1.EnvVar{
+			env,
+		},
+	}
+}
+
+func newServiceMonitor(mysqlObj *mysqlalpha1.MySQL) *unstructured.Unstructured {
+	labels := map[string]interface{}{}
+	for k, v := range metricsServiceLabels(mysqlObj) {
+		labels[k] = v
+	}
+	endpoint := map[string]interface{}{
+		"port": exporterPortName,
+	}
+	if len(mysqlObj.Spec.Metrics.Relabelings) > 0 {
+		endpoint["relabelings"] = relabelConfigs(mysqlObj.Spec.Metrics.Relabelings)
+	}
+	if len(mysqlObj.Spec.Metrics.MetricRelabelings) > 0 {
+		endpoint["metricRelabelings"] = relabelConfigs(mysqlObj.Spec.Metrics.MetricRelabelings)
+	}
+	spec := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": labels,
+		},
+		"endpoints": []interface{}{endpoint},
+	}
+	ownerRef := newOwnerRef(mysqlObj)
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": serviceMonitorGroupVersion.String(),
+			"kind":       "ServiceMonitor",
+			"metadata": map[string]interface{}{
+				"name":      metricsServiceName(mysqlObj),
+				"namespace": mysqlObj.Namespace,
+				"annotations": map[string]interface{}{
+					specHashAnnotation: hashObject(spec),
+				},
+				"ownerReferences": []interface{}{
+					map[string]interface{}{
+						"apiVersion":         ownerRef.APIVersion,
+						"kind":               ownerRef.Kind,
+						"name":               ownerRef.Name,
+						"uid":                string(ownerRef.UID),
+						"controller":         true,
+						"blockOwnerDeletion": true,
+					},
+				},
+			},
+			"spec": spec,
+		},
+	}
+}
+
+// relabelConfigs returns the relabelings of the spec as unstructured
+// RelabelConfigs of the Prometheus Operator.
+func relabelConfigs(configs []mysqlalpha1.MySQLRelabelConfig) []interface{} {
+	ret := make([]interface{}, 0, len(configs))
+	for _, config := range configs {
+		m := map[string]interface{}{}
+		if len(config.SourceLabels) > 0 {
+			sourceLabels := make([]interface{}, 0, len(config.SourceLabels))
+			for _, label := range config.SourceLabels {
+				sourceLabels = append(sourceLabels, label)
+			}
+			m["sourceLabels"] = sourceLabels
+		}
+		for key, value := range map[string]string{
+			"separator":   config.Separator,
+			"targetLabel": config.TargetLabel,
+			"regex":       config.Regex,
+			"action":      config.Action,
+		} {
+			if value != "" {
+				m[key] = value
+			}
+		}
+		if config.Modulus != 0 {
+			m["modulus"] = config.Modulus
+		}
+		if config.Replacement != nil {
+			m["replacement"] = *config.Replacement
+		}
+		ret = append(ret, m)
+	}
+	return ret
+}
@@ -0,0 +1,77 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/cyhw/mysql-operator/pkg/controller"
+	ctrltesting "github.com/cyhw/mysql-operator/pkg/controller/testing"
+)
+
+func TestReconcileConvergesMetricsService(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	mysqlObj.Spec.Metrics.Enabled = true
+	mysqlObj.Spec.Metrics.ServiceMonitor = true
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	services := f.K8sClient.CoreV1().Services("ns")
+	if _, err := services.Get(context.Background(), "db-metrics", metav1.GetOptions{}); err != nil {
+		t.Fatalf("metrics service: %v", err)
+	}
+
+	f.K8sClient.ClearActions()
+	for _, tc := range []struct {
+		name   string
+		mutate func() error
+	}{
+		{
+			name: "deleted",
+			mutate: func() error {
+				return services.Delete(context.Background(), "db-metrics", metav1.DeleteOptions{})
+			},
+		},
+		{
+			name: "drifted",
+			mutate: func() error {
+				service, err := services.Get(context.Background(), "db-metrics", metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				service.Spec.Ports[0].TargetPort = intstr.FromInt(8080)
+				_, err = services.Update(context.Background(), service, metav1.UpdateOptions{})
+				return err
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.mutate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Reconcile(mysqlObj); err != nil {
+				t.Fatalf("Reconcile() = %v", err)
+			}
+			service, err := services.Get(context.Background(), "db-metrics", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("metrics service: %v", err)
+			}
+			if port := service.Spec.Ports[0]; port.TargetPort != intstr.FromString("metrics") || port.Protocol != corev1.ProtocolTCP {
+				t.Errorf("metrics service port = %v, want TCP to the metrics port", port)
+			}
+		})
+	}
+
+	// The missing ServiceMonitor CRD was discovered by the first reconcile.
+	for _, action := range f.K8sClient.Actions() {
+		if action.GetVerb() == "get" && action.GetResource().Resource == "resource" {
+			t.Errorf("discovery is not cached")
+			break
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// syncNetworkPolicy converges the NetworkPolicy restricting the ingress to the
+// MySQL pods, or removes it when no longer requested.
+func (c *Controller) syncNetworkPolicy(mysqlObj *mysqlalpha1.MySQL) error {
+	name := networkPolicyName(mysqlObj)
+	current, err := c.networkPolicyLister.NetworkPolicies(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+
+	if mysqlObj.Spec.NetworkPolicy == nil {
+		if current == nil {
+			return nil
+		}
+		err = c.k8sClient.NetworkingV1().NetworkPolicies(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete network policy.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+
+	desired := newNetworkPolicy(mysqlObj)
+	if current == nil {
+		_, err = c.k8sClient.NetworkingV1().NetworkPolicies(mysqlObj.Namespace).Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create network policy.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if current.Annotations[specHashAnnotation] == desired.Annotations[specHashAnnotation] {
+		return nil
+	}
+	ret := current.DeepCopy()
+	ret.Annotations = desired.Annotations
+	ret.Spec = desired.Spec
+	_, err = c.k8sClient.NetworkingV1().NetworkPolicies(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update network policy.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// networkPolicyUpToDate reports whether the NetworkPolicy matches the spec.
+func (c *Controller) networkPolicyUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	current, err := c.networkPolicyLister.NetworkPolicies(mysqlObj.Namespace).Get(networkPolicyName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		return mysqlObj.Spec.NetworkPolicy == nil, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if mysqlObj.Spec.NetworkPolicy == nil {
+		return false, nil
+	}
+	return current.Annotations[specHashAnnotation] == newNetworkPolicy(mysqlObj).Annotations[specHashAnnotation], nil
+}
+
+func networkPolicyName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-mysql"
+}
+
+// newNetworkPolicy allows ingress to the MySQL port from the allowed sources,
+// the other pods of the instance and the Jobs run against it. Selecting the
+// pods denies every other ingress, except the scrapes of the exporter.
+func newNetworkPolicy(mysqlObj *mysqlalpha1.MySQL) *networkingv1.NetworkPolicy {
+	// Replicas clone from and replicate from their peers, and backup, restore
+	// and promote Jobs connect to the servers.
+	peers := []networkingv1.NetworkPolicyPeer{
+		{
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: LabelsForInstance(mysqlObj.Name),
+			},
+		},
+		{
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: jobPodLabels(mysqlObj),
+			},
+		},
+	}
+	if namespaces := mysqlObj.Spec.NetworkPolicy.AllowedNamespaces; len(namespaces) > 0 {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{
+						Key:      corev1.LabelMetadataName,
+						Operator: metav1.LabelSelectorOpIn,
+						Values:   namespaces,
+					},
+				},
+			},
+		})
+	}
+	for i := range mysqlObj.Spec.NetworkPolicy.AllowedPodSelectors {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector: &mysqlObj.Spec.NetworkPolicy.AllowedPodSelectors[i],
+		})
+	}
+	if mysqlObj.Spec.Proxy.Enabled {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: proxyLabels(mysqlObj),
+			},
+		})
+	}
+
+	spec := networkingv1.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{
+			MatchLabels: LabelsForInstance(mysqlObj.Name),
+		},
+		PolicyTypes: []networkingv1.PolicyType{
+			networkingv1.PolicyTypeIngress,
+		},
+	}
+	spec.Ingress = []networkingv1.NetworkPolicyIngressRule{
+		{
+			From:  peers,
+			Ports: networkPolicyPorts(mysqlObj),
+		},
+	}
+	// Prometheus runs in a namespace of its own, so the exporter is open to all.
+	if mysqlObj.Spec.Metrics.Enabled {
+		protocol := corev1.ProtocolTCP
+		port := intstr.FromInt(int(exporterPort))
+		spec.Ingress = append(spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{
+					Protocol: &protocol,
+					Port:     &port,
+				},
+			},
+		})
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      networkPolicyName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+			Annotations: map[string]string{
+				specHashAnnotation: hashObject(spec),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: spec,
+	}
+}
+
+// networkPolicyPorts returns the MySQL port and the target ports of
+// spec.additionalServicePorts.
+func networkPolicyPorts(mysqlObj *mysqlalpha1.MySQL) []networkingv1.NetworkPolicyPort {
+	ports := make([]networkingv1.NetworkPolicyPort, 0, len(mysqlObj.Spec.AdditionalServicePorts)+1)
+	for _, port := range clientServicePorts(mysqlObj) {
+		target := port.TargetPort
+		if port.Name == portName {
+			target = intstr.FromInt(int(mysqlPort(mysqlObj)))
+		}
+		protocol := port.Protocol
+		ports = append(ports, networkingv1.NetworkPolicyPort{
+			Protocol: &protocol,
+			Port:     &target,
+		})
+	}
+	return ports
+}
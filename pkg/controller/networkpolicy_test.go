@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+func TestNetworkPolicyAdmitsInstanceAndJobs(t *testing.T) {
+	mysqlObj := &mysqlalpha1.MySQL{}
+	mysqlObj.Namespace, mysqlObj.Name = "ns", "db"
+	mysqlObj.Spec.NetworkPolicy = &mysqlalpha1.MySQLNetworkPolicySpec{}
+	mysqlObj.Spec.Metrics.Enabled = true
+
+	policy := newNetworkPolicy(mysqlObj)
+	if len(policy.Spec.Ingress) != 2 {
+		t.Fatalf("%d ingress rules, want the MySQL and exporter rules", len(policy.Spec.Ingress))
+	}
+
+	// An empty spec still admits the replicas and the Jobs of the instance.
+	admitted := func(podLabels map[string]string) bool {
+		for _, peer := range policy.Spec.Ingress[0].From {
+			if peer.PodSelector == nil || peer.NamespaceSelector != nil {
+				continue
+			}
+			if labels.SelectorFromSet(peer.PodSelector.MatchLabels).Matches(labels.Set(podLabels)) {
+				return true
+			}
+		}
+		return false
+	}
+	for name, podLabels := range map[string]map[string]string{
+		"replica": LabelsForInstance("db"),
+		"job":     jobPodLabels(mysqlObj),
+	} {
+		if !admitted(podLabels) {
+			t.Errorf("%s pods are not admitted", name)
+		}
+	}
+	if admitted(LabelsForInstance("other")) {
+		t.Errorf("pods of another instance are admitted")
+	}
+	// The Job pods are not selected as servers.
+	if labels.SelectorFromSet(policy.Spec.PodSelector.MatchLabels).Matches(labels.Set(jobPodLabels(mysqlObj))) {
+		t.Errorf("the policy selects the Job pods")
+	}
+
+	exporter := policy.Spec.Ingress[1]
+	if len(exporter.From) != 0 {
+		t.Errorf("exporter rule sources = %v, want any", exporter.From)
+	}
+	if len(exporter.Ports) != 1 || *exporter.Ports[0].Port != intstr.FromInt(int(exporterPort)) {
+		t.Errorf("exporter rule ports = %v, want %d", exporter.Ports, exporterPort)
+	}
+}
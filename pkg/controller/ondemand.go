@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// backupRequestAnnotation requests a backup outside the schedule. Every new
+// value, such as a timestamp, runs one backup.
+var backupRequestAnnotation = "volc.bytedance.com/backup-request"
+
+// syncOnDemandBackup runs the backup requested by the annotation and records
+// its progress in the status.
+func (c *Controller) syncOnDemandBackup(mysqlObj *mysqlalpha1.MySQL) error {
+	request := mysqlObj.Annotations[backupRequestAnnotation]
+	if request == "" {
+		return nil
+	}
+	backup := mysqlObj.Status.OnDemandBackup
+	if backup != nil && backup.Request == request && backup.Phase != mysqlalpha1.BackupPhaseRunning {
+		return nil
+	}
+
+	if backup == nil || backup.Request != request {
+		job := newOnDemandBackupJob(mysqlObj, request)
+		_, err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Create(context.Background(), job, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		klog.InfoS("Create on-demand backup job.", "namespace", job.Namespace, "name", job.Name, "request", request)
+		mysqlObj.Status.OnDemandBackup = &mysqlalpha1.MySQLOnDemandBackupStatus{
+			Request: request,
+			JobName: job.Name,
+			Phase:   mysqlalpha1.BackupPhaseRunning,
+		}
+		c.enqueueAfter(mysqlObj, backupPollInterval)
+		return nil
+	}
+
+	job, err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Get(context.Background(), backup.JobName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		backup.Phase = mysqlalpha1.BackupPhaseFailed
+		now := metav1.Now()
+		backup.CompletionTime = &now
+		klog.InfoS("On-demand backup job is gone.", "namespace", mysqlObj.Namespace, "name", backup.JobName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			backup.Phase = mysqlalpha1.BackupPhaseSucceeded
+		case batchv1.JobFailed:
+			backup.Phase = mysqlalpha1.BackupPhaseFailed
+		default:
+			continue
+		}
+		completion := cond.LastTransitionTime
+		backup.CompletionTime = &completion
+		klog.InfoS("Finish on-demand backup.", "namespace", job.Namespace, "name", job.Name, "phase", backup.Phase)
+		return nil
+	}
+	c.enqueueAfter(mysqlObj, backupPollInterval)
+	return nil
+}
+
+func newOnDemandBackupJob(mysqlObj *mysqlalpha1.MySQL, request string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-backup-%s", mysqlObj.Name, hashObject(request)),
+			Namespace: mysqlObj.Namespace,
+			Labels:    backupJobLabels(mysqlObj),
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: newBackupJobSpec(mysqlObj, backupCommand(mysqlObj)),
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// syncOrphans deletes the children controlled by a MySQL that are no longer
+// part of its desired set, such as the ones named by an older release of the
+// operator or the StatefulSet retired by a blue/green upgrade.
+func (c *Controller) syncOrphans(mysqlObj *mysqlalpha1.MySQL) error {
+	statefulSets, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, sts := range statefulSets {
+		if !isOrphanOf(mysqlObj, sts, statefulSetName(mysqlObj), candidateStatefulSetName(mysqlObj)) {
+			continue
+		}
+		err = c.k8sClient.AppsV1().StatefulSets(sts.Namespace).Delete(context.Background(), sts.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete orphaned statefulset.", "namespace", sts.Namespace, "name", sts.Name)
+	}
+
+	cronJobs, err := c.cronJobLister.CronJobs(mysqlObj.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, cronJob := range cronJobs {
+		if !isOrphanOf(mysqlObj, cronJob, backupCronJobName(mysqlObj)) {
+			continue
+		}
+		err = c.k8sClient.BatchV1().CronJobs(cronJob.Namespace).Delete(context.Background(), cronJob.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete orphaned cronjob.", "namespace", cronJob.Namespace, "name", cronJob.Name)
+	}
+
+	secrets, err := c.secretLister.Secrets(mysqlObj.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		if !isOrphanOf(mysqlObj, secret, connectionSecretName(mysqlObj)) {
+			continue
+		}
+		err = c.k8sClient.CoreV1().Secrets(secret.Namespace).Delete(context.Background(), secret.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete orphaned secret.", "namespace", secret.Namespace, "name", secret.Name)
+	}
+	return nil
+}
+
+// isOrphanOf reports whether the object is controlled by the MySQL under a
+// name other than the desired ones.
+func isOrphanOf(mysqlObj *mysqlalpha1.MySQL, object metav1.Object, desired ...string) bool {
+	ownerRef := metav1.GetControllerOf(object)
+	return ownerRef != nil && ownerRef.UID == mysqlObj.UID && !containsString(desired, object.GetName())
+}
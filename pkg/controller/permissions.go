@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"context"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// managedResources are the resources the controller creates and deletes in
+// the namespaces of the MySQLs.
+var managedResources = []struct {
+	group    string
+	resource string
+}{
+	{"", "secrets"},
+	{"", "services"},
+	{"", "configmaps"},
+	{"", "persistentvolumeclaims"},
+	{"apps", "statefulsets"},
+	{"apps", "deployments"},
+	{"batch", "jobs"},
+	{"batch", "cronjobs"},
+	{"networking.k8s.io", "networkpolicies"},
+}
+
+// checkPermissions asks the API server whether the operator may create and
+// delete the managed resources in the watched namespace, or in all namespaces
+// when it watches them all, and logs a warning for each missing permission.
+// Without them the reconciles fail one by one, with errors easy to mistake
+// for transient ones.
+func (c *Controller) checkPermissions(ctx context.Context) {
+	scope := "all namespaces"
+	if c.options.Namespace != metav1.NamespaceAll {
+		scope = "namespace " + c.options.Namespace
+	}
+	for _, r := range managedResources {
+		for _, verb := range []string{"create", "delete"} {
+			review := &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: c.options.Namespace,
+						Verb:      verb,
+						Group:     r.group,
+						Resource:  r.resource,
+					},
+				},
+			}
+			ret, err := c.k8sClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+			if err != nil {
+				klog.ErrorS(err, "Failed to review permissions")
+				return
+			}
+			if !ret.Status.Allowed {
+				klog.Warningf("Missing permission to %s %s in %s, check the RBAC of the operator.", verb, schema.GroupResource{Group: r.group, Resource: r.resource}, scope)
+			}
+		}
+	}
+}
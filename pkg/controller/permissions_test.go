@@ -0,0 +1,37 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/cyhw/mysql-operator/pkg/controller"
+	ctrltesting "github.com/cyhw/mysql-operator/pkg/controller/testing"
+)
+
+func TestCheckPermissionsScope(t *testing.T) {
+	for _, namespace := range []string{"", "ns"} {
+		f := ctrltesting.NewFixture(nil, nil, controller.Options{Namespace: namespace})
+		f.K8sClient.ClearActions()
+		f.Controller.CheckPermissions(context.Background())
+
+		var reviews int
+		for _, action := range f.K8sClient.Actions() {
+			create, ok := action.(clienttesting.CreateAction)
+			if !ok || action.GetResource().Resource != "selfsubjectaccessreviews" {
+				continue
+			}
+			reviews++
+			review := create.GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			if got := review.Spec.ResourceAttributes.Namespace; got != namespace {
+				t.Errorf("watching %q, reviewed %s %s in namespace %q", namespace,
+					review.Spec.ResourceAttributes.Verb, review.Spec.ResourceAttributes.Resource, got)
+			}
+		}
+		if reviews == 0 {
+			t.Errorf("watching %q, no permission reviewed", namespace)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	podGroupGroupVersion = schema.GroupVersion{Group: "scheduling.volcano.sh", Version: "v1beta1"}
+	podGroupResource     = podGroupGroupVersion.WithResource("podgroups")
+	// podGroupAnnotation names the PodGroup of a pod for the Volcano
+	// scheduler.
+	podGroupAnnotation = "scheduling.k8s.io/group-name"
+)
+
+// syncPodGroup converges the Volcano PodGroup gang scheduling the pods of a
+// multi-replica MySQL. It is skipped when Volcano is not installed, the pods
+// are then placed one by one.
+func (c *Controller) syncPodGroup(mysqlObj *mysqlalpha1.MySQL) error {
+	available, err := c.resourceAvailable(podGroupResource)
+	if err != nil {
+		return err
+	}
+	if !available {
+		if gangScheduled(mysqlObj) {
+			klog.InfoS("PodGroup is not available, skip gang scheduling.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name)
+		}
+		return nil
+	}
+
+	name := podGroupName(mysqlObj)
+	client := c.dynamicClient.Resource(podGroupResource).Namespace(mysqlObj.Namespace)
+	current, err := client.Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+
+	if !gangScheduled(mysqlObj) {
+		if current == nil {
+			return nil
+		}
+		err = client.Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete pod group.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+
+	desired := newPodGroup(mysqlObj)
+	if current == nil {
+		_, err = client.Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create pod group.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if current.GetAnnotations()[specHashAnnotation] == desired.GetAnnotations()[specHashAnnotation] {
+		return nil
+	}
+	desired.SetResourceVersion(current.GetResourceVersion())
+	_, err = client.Update(context.Background(), desired, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update pod group.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// gangScheduled reports whether the pods of the MySQL are scheduled together.
+// A single pod needs no gang, and the pods created one at a time by an
+// OrderedReady StatefulSet would never form one.
+func gangScheduled(mysqlObj *mysqlalpha1.MySQL) bool {
+	return mysqlObj.Spec.GangScheduling && specReplicas(mysqlObj) > 1 &&
+		mysqlObj.Spec.PodManagementPolicy == appsv1.ParallelPodManagement
+}
+
+func podGroupName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name
+}
+
+func newPodGroup(mysqlObj *mysqlalpha1.MySQL) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"minMember": int64(specReplicas(mysqlObj)),
+	}
+	if mysqlObj.Spec.PriorityClassName != "" {
+		spec["priorityClassName"] = mysqlObj.Spec.PriorityClassName
+	}
+	ownerRef := newOwnerRef(mysqlObj)
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": podGroupGroupVersion.String(),
+			"kind":       "PodGroup",
+			"metadata": map[string]interface{}{
+				"name":      podGroupName(mysqlObj),
+				"namespace": mysqlObj.Namespace,
+				"annotations": map[string]interface{}{
+					specHashAnnotation: hashObject(spec),
+				},
+				"ownerReferences": []interface{}{
+					map[string]interface{}{
+						"apiVersion":         ownerRef.APIVersion,
+						"kind":               ownerRef.Kind,
+						"name":               ownerRef.Name,
+						"uid":                string(ownerRef.UID),
+						"controller":         true,
+						"blockOwnerDeletion": true,
+					},
+				},
+			},
+			"spec": spec,
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+func TestGangScheduled(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		replicas int32
+		policy   appsv1.PodManagementPolicyType
+		want     bool
+	}{
+		{"parallel", 3, appsv1.ParallelPodManagement, true},
+		{"single replica", 1, appsv1.ParallelPodManagement, false},
+		{"ordered ready", 3, appsv1.OrderedReadyPodManagement, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mysqlObj := &mysqlalpha1.MySQL{}
+			mysqlObj.Spec.GangScheduling = true
+			mysqlObj.Spec.Replicas = &tc.replicas
+			mysqlObj.Spec.PodManagementPolicy = tc.policy
+			if got := gangScheduled(mysqlObj); got != tc.want {
+				t.Errorf("gangScheduled() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
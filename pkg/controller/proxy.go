@@ -0,0 +1,491 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	proxyContainerName = "proxysql"
+	proxyImage         = "proxysql/proxysql:2.5.5"
+	proxyLabelVal      = "proxysql"
+	proxyPort          = int32(6033)
+	proxyPortName      = "proxy"
+	proxyConfigKey     = "proxysql.cnf"
+	proxyConfigPath    = "/etc/proxysql.cnf"
+	proxyDataVolume    = "proxysql-data"
+	proxyDataPath      = "/var/lib/proxysql"
+	proxyConfigVolume  = "proxysql-config"
+	proxyUser          = "proxysql"
+	proxyPasswordKey   = "password"
+	// proxyPrivileges are granted to the proxy user: the ones of an
+	// application, and REPLICATION CLIENT for the monitor of ProxySQL.
+	proxyPrivileges        = "SELECT, INSERT, UPDATE, DELETE, CREATE, DROP, INDEX, ALTER, CREATE TEMPORARY TABLES, LOCK TABLES, EXECUTE, CREATE VIEW, SHOW VIEW, CREATE ROUTINE, ALTER ROUTINE, EVENT, TRIGGER, PROCESS, REPLICATION CLIENT"
+	proxyPasswordEnvName   = "PROXY_PASSWORD"
+	proxyUserContainerName = "proxy-user"
+)
+
+// syncProxy converges the ProxySQL Deployment, its config Secret and its
+// Service, or removes them when the proxy is disabled. ProxySQL connects as a
+// dedicated user, whose password is generated into the config Secret.
+func (c *Controller) syncProxy(mysqlObj *mysqlalpha1.MySQL) error {
+	if !mysqlObj.Spec.Proxy.Enabled {
+		return c.deleteProxy(mysqlObj)
+	}
+
+	secret, err := c.syncProxySecret(mysqlObj)
+	if err != nil {
+		return err
+	}
+	if err := c.syncProxyUser(mysqlObj, string(secret.Data[proxyPasswordKey])); err != nil {
+		return err
+	}
+	if err := c.syncProxyDeployment(mysqlObj, hashObject(string(secret.Data[proxyConfigKey]))); err != nil {
+		return err
+	}
+	return c.syncProxyService(mysqlObj)
+}
+
+// syncProxySecret converges the Secret holding the ProxySQL config and the
+// password of the proxy user, which is kept once generated.
+func (c *Controller) syncProxySecret(mysqlObj *mysqlalpha1.MySQL) (*corev1.Secret, error) {
+	name := proxyName(mysqlObj)
+	current, err := c.secretLister.Secrets(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		password, err := newProxyPassword()
+		if err != nil {
+			return nil, err
+		}
+		secret := newProxySecret(mysqlObj, password)
+		_, err = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		klog.InfoS("Create proxy config.", "namespace", mysqlObj.Namespace, "name", name)
+		return secret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	desired := newProxySecret(mysqlObj, string(current.Data[proxyPasswordKey]))
+	if len(current.Data[proxyPasswordKey]) == 0 {
+		password, err := newProxyPassword()
+		if err != nil {
+			return nil, err
+		}
+		desired = newProxySecret(mysqlObj, password)
+	}
+	if equality.Semantic.DeepEqual(current.Data, desired.Data) && equality.Semantic.DeepEqual(current.Labels, desired.Labels) {
+		return current, nil
+	}
+	ret := current.DeepCopy()
+	ret.Labels = desired.Labels
+	ret.Data = desired.Data
+	_, err = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	klog.InfoS("Update proxy config.", "namespace", mysqlObj.Namespace, "name", name)
+	return ret, nil
+}
+
+// syncProxyUser runs the Job creating the proxy user with the password of the
+// Secret. The Job is run again when the password changes.
+func (c *Controller) syncProxyUser(mysqlObj *mysqlalpha1.MySQL, password string) error {
+	name := proxyUserJobName(mysqlObj)
+	desired := newProxyUserJob(mysqlObj, password)
+	current, err := c.jobLister.Jobs(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		_, err = c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create proxy user job.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current.Annotations[passwordHashAnnotation] == desired.Annotations[passwordHashAnnotation] || current.DeletionTimestamp != nil {
+		return nil
+	}
+	propagation := metav1.DeletePropagationBackground
+	err = c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	klog.InfoS("Delete outdated proxy user job.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// syncProxyDeployment converges the ProxySQL Deployment. ProxySQL only reads
+// its config file on first start, so a config change rolls the pods.
+func (c *Controller) syncProxyDeployment(mysqlObj *mysqlalpha1.MySQL, checksum string) error {
+	name := proxyName(mysqlObj)
+	desired := newProxyDeployment(mysqlObj, checksum)
+	current, err := c.deploymentLister.Deployments(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		_, err = c.k8sClient.AppsV1().Deployments(mysqlObj.Namespace).Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create proxy deployment.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current.Annotations[specHashAnnotation] == desired.Annotations[specHashAnnotation] &&
+		equality.Semantic.DeepEqual(current.Labels, desired.Labels) {
+		return nil
+	}
+	ret := current.DeepCopy()
+	ret.Labels = desired.Labels
+	ret.Annotations = desired.Annotations
+	ret.Spec.Replicas = desired.Spec.Replicas
+	ret.Spec.Template = desired.Spec.Template
+	_, err = c.k8sClient.AppsV1().Deployments(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update proxy deployment.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// syncProxyService creates the Service clients connect to through the proxy,
+// or updates its labels, annotations and port.
+func (c *Controller) syncProxyService(mysqlObj *mysqlalpha1.MySQL) error {
+	name := proxyName(mysqlObj)
+	desired := newProxyService(mysqlObj)
+	current, err := c.serviceLister.Services(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		_, err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create proxy service.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if proxyServiceMatches(current, desired) {
+		return nil
+	}
+	ret := current.DeepCopy()
+	setServicePort(ret, mysqlPort(mysqlObj))
+	ret.Labels = desired.Labels
+	ret.Annotations = desired.Annotations
+	_, err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update proxy service.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// proxyUpToDate reports whether the proxy objects match the spec, so deleted
+// or edited ones are converged again.
+func (c *Controller) proxyUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	name := proxyName(mysqlObj)
+	secret, err := c.secretLister.Secrets(mysqlObj.Namespace).Get(name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	deployment, err := c.deploymentLister.Deployments(mysqlObj.Namespace).Get(name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	service, err := c.serviceLister.Services(mysqlObj.Namespace).Get(name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	if !mysqlObj.Spec.Proxy.Enabled {
+		return secret == nil && deployment == nil && service == nil, nil
+	}
+	if secret == nil || deployment == nil || service == nil {
+		return false, nil
+	}
+
+	desiredSecret := newProxySecret(mysqlObj, string(secret.Data[proxyPasswordKey]))
+	desiredDeployment := newProxyDeployment(mysqlObj, hashObject(string(desiredSecret.Data[proxyConfigKey])))
+	return equality.Semantic.DeepEqual(secret.Data, desiredSecret.Data) &&
+		deployment.Annotations[specHashAnnotation] == desiredDeployment.Annotations[specHashAnnotation] &&
+		proxyServiceMatches(service, newProxyService(mysqlObj)), nil
+}
+
+// deleteProxy removes the objects of a disabled proxy, ignoring the ones
+// already gone.
+func (c *Controller) deleteProxy(mysqlObj *mysqlalpha1.MySQL) error {
+	name := proxyName(mysqlObj)
+	err := c.k8sClient.AppsV1().Deployments(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+	if err == nil {
+		klog.InfoS("Delete proxy deployment.", "namespace", mysqlObj.Namespace, "name", name)
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+	err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	err = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	propagation := metav1.DeletePropagationBackground
+	err = c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Delete(context.Background(), proxyUserJobName(mysqlObj), metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func proxyName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-proxy"
+}
+
+// proxyLabels select the ProxySQL pods. They differ from the MySQL pod labels
+// so the proxy is not taken for a MySQL pod. The proxy objects themselves carry
+// the labels of the instance, which the informers of the controller select.
+func proxyLabels(mysqlObj *mysqlalpha1.MySQL) map[string]string {
+	ret := LabelsForInstance(mysqlObj.Name)
+	ret[matchLabelKey] = proxyLabelVal
+	return ret
+}
+
+// proxyConfig renders the ProxySQL config. The backend is the client Service,
+// which routes to the ready pods of the serving StatefulSet and follows the
+// blue/green switches.
+func proxyConfig(mysqlObj *mysqlalpha1.MySQL, password string) string {
+	lines := []string{
+		fmt.Sprintf("datadir=%s", strconv.Quote(proxyDataPath)),
+		"mysql_variables=",
+		"{",
+		fmt.Sprintf("\tinterfaces=%s", strconv.Quote(fmt.Sprintf("0.0.0.0:%d", proxyPort))),
+		fmt.Sprintf("\tmonitor_username=%s", strconv.Quote(proxyUser)),
+		fmt.Sprintf("\tmonitor_password=%s", strconv.Quote(password)),
+		"}",
+		"mysql_servers=",
+		"(",
+		fmt.Sprintf("\t{ address=%s, port=%d, hostgroup=0 }", strconv.Quote(clientServiceHost(mysqlObj)), mysqlPort(mysqlObj)),
+		")",
+		"mysql_users=",
+		"(",
+		fmt.Sprintf("\t{ username=%s, password=%s, default_hostgroup=0 }", strconv.Quote(proxyUser), strconv.Quote(password)),
+		")",
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// newProxyPassword generates the password of the proxy user.
+func newProxyPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func newProxySecret(mysqlObj *mysqlalpha1.MySQL, password string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      proxyName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+			Labels:    LabelsForInstance(mysqlObj.Name),
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			proxyConfigKey:   []byte(proxyConfig(mysqlObj, password)),
+			proxyPasswordKey: []byte(password),
+		},
+	}
+}
+
+func newProxyService(mysqlObj *mysqlalpha1.MySQL) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        proxyName(mysqlObj),
+			Namespace:   mysqlObj.Namespace,
+			Labels:      LabelsForInstance(mysqlObj.Name),
+			Annotations: mergeAnnotations(mysqlObj.Spec.Service.Annotations, nil),
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       portName,
+					Port:       mysqlPort(mysqlObj),
+					TargetPort: intstr.FromString(proxyPortName),
+				},
+			},
+			Selector: proxyLabels(mysqlObj),
+		},
+	}
+}
+
+// proxyServiceMatches reports whether the fields of the proxy Service set by
+// the controller after its creation are the desired ones.
+func proxyServiceMatches(current, desired *corev1.Service) bool {
+	ret := current.DeepCopy()
+	portChanged := setServicePort(ret, desired.Spec.Ports[0].Port)
+	return !portChanged && equality.Semantic.DeepEqual(current.Labels, desired.Labels) &&
+		equality.Semantic.DeepEqual(current.Annotations, desired.Annotations)
+}
+
+func proxyUserJobName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-proxy-user"
+}
+
+// newProxyUserJob creates or updates the proxy user on the server, with the
+// privileges of an application and the ones ProxySQL monitors the server
+// with, but no administrative ones.
+func newProxyUserJob(mysqlObj *mysqlalpha1.MySQL, password string) *batchv1.Job {
+	f := flavorOf(mysqlObj)
+	account := fmt.Sprintf("'%s'@'%%'", proxyUser)
+	statements := strings.Join([]string{
+		fmt.Sprintf("CREATE USER IF NOT EXISTS %s IDENTIFIED BY '$%s'", account, proxyPasswordEnvName),
+		fmt.Sprintf("ALTER USER %s IDENTIFIED BY '$%s'", account, proxyPasswordEnvName),
+		fmt.Sprintf("GRANT %s ON *.* TO %s", proxyPrivileges, account),
+	}, "; ")
+	command := fmt.Sprintf("%s -h %s%s -uroot -p\"$%s\" -e \"%s\"",
+		f.client, clientServiceHost(mysqlObj), portArg(mysqlObj), f.passwordEnv, statements)
+
+	spec := newBackupJobSpec(mysqlObj, command)
+	spec.Template.Spec.Containers[0].Name = proxyUserContainerName
+	spec.Template.Spec.Containers[0].Env = append(spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name: proxyPasswordEnvName,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: proxyName(mysqlObj),
+				},
+				Key: proxyPasswordKey,
+			},
+		},
+	})
+	// The user is created over the network, the backup claim is not needed.
+	spec.Template.Spec.Containers[0].VolumeMounts = nil
+	spec.Template.Spec.Volumes = nil
+	labels := LabelsForInstance(mysqlObj.Name)
+	labels[jobKindLabelKey] = proxyUserContainerName
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      proxyUserJobName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				passwordHashAnnotation: hashObject(password),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: spec,
+	}
+}
+
+func newProxyDeployment(mysqlObj *mysqlalpha1.MySQL, checksum string) *appsv1.Deployment {
+	image := mysqlObj.Spec.Proxy.Image
+	if image == "" {
+		image = proxyImage
+	}
+	replicas := int32(1)
+	if mysqlObj.Spec.Proxy.Replicas != nil {
+		replicas = *mysqlObj.Spec.Proxy.Replicas
+	}
+
+	spec := appsv1.DeploymentSpec{
+		Replicas: &replicas,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: proxyLabels(mysqlObj),
+		},
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: proxyLabels(mysqlObj),
+				Annotations: map[string]string{
+					configChecksumAnnotation: checksum,
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:  proxyContainerName,
+						Image: image,
+						Args:  []string{"-f", "-c", proxyConfigPath},
+						Ports: []corev1.ContainerPort{
+							{
+								Name:          proxyPortName,
+								ContainerPort: proxyPort,
+							},
+						},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								TCPSocket: &corev1.TCPSocketAction{
+									Port: intstr.FromString(proxyPortName),
+								},
+							},
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{
+								Name:      proxyConfigVolume,
+								MountPath: proxyConfigPath,
+								SubPath:   proxyConfigKey,
+								ReadOnly:  true,
+							},
+							{
+								Name:      proxyDataVolume,
+								MountPath: proxyDataPath,
+							},
+						},
+					},
+				},
+				Volumes: []corev1.Volume{
+					{
+						Name: proxyConfigVolume,
+						VolumeSource: corev1.VolumeSource{
+							Secret: &corev1.SecretVolumeSource{
+								SecretName: proxyName(mysqlObj),
+							},
+						},
+					},
+					newEmptyDirVolume(proxyDataVolume),
+				},
+			},
+		},
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      proxyName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+			Labels:    LabelsForInstance(mysqlObj.Name),
+			Annotations: map[string]string{
+				specHashAnnotation: hashObject(spec),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: spec,
+	}
+}
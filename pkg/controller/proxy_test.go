@@ -0,0 +1,75 @@
+package controller_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/cyhw/mysql-operator/pkg/controller"
+	ctrltesting "github.com/cyhw/mysql-operator/pkg/controller/testing"
+)
+
+func TestReconcileProxy(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	mysqlObj.Spec.Proxy.Enabled = true
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	var secret *corev1.Secret
+	for _, obj := range f.Created("secrets") {
+		if obj.(*corev1.Secret).Name == "db-proxy" {
+			secret = obj.(*corev1.Secret)
+		}
+	}
+	if secret == nil {
+		t.Fatalf("proxy secret not created")
+	}
+	if secret.Labels["app"] != "mysql" {
+		t.Errorf("proxy secret labels = %v, want the managed labels", secret.Labels)
+	}
+	config := string(secret.Data["proxysql.cnf"])
+	password := string(secret.Data["password"])
+	if password == "" || !strings.Contains(config, `username="proxysql", password="`+password+`"`) {
+		t.Errorf("proxy config does not use the proxy user:\n%s", config)
+	}
+	if strings.Contains(config, `"root"`) {
+		t.Errorf("proxy config holds root credentials:\n%s", config)
+	}
+	jobs := f.Created("jobs")
+	if len(jobs) != 1 || jobs[0].(*batchv1.Job).Name != "db-proxy-user" {
+		t.Errorf("created jobs %v, want the proxy user job", jobs)
+	}
+	if len(f.Created("deployments")) != 1 {
+		t.Fatalf("proxy deployment not created")
+	}
+
+	// A deleted proxy child is created again, with the same password.
+	err := f.K8sClient.AppsV1().Deployments("ns").Delete(context.Background(), "db-proxy", metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.K8sClient.ClearActions()
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	deployments := f.Created("deployments")
+	if len(deployments) != 1 {
+		t.Fatalf("created %d proxy deployments after the deletion, want 1", len(deployments))
+	}
+	if labels := deployments[0].(*appsv1.Deployment).Labels; labels["app"] != "mysql" {
+		t.Errorf("proxy deployment labels = %v, want the managed labels", labels)
+	}
+	for _, resource := range []string{"secrets", "jobs"} {
+		if created := f.Created(resource); len(created) != 0 {
+			t.Errorf("created %d %s again", len(created), resource)
+		}
+	}
+}
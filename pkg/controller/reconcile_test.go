@@ -0,0 +1,519 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+	"github.com/cyhw/mysql-operator/pkg/controller"
+	ctrltesting "github.com/cyhw/mysql-operator/pkg/controller/testing"
+)
+
+func TestReconcileCreatesChildren(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	secrets := f.Created("secrets")
+	if len(secrets) != 1 || secrets[0].(*corev1.Secret).Name != "mysql-password" {
+		t.Errorf("created secrets = %v, want mysql-password", secrets)
+	}
+	var services []string
+	for _, obj := range f.Created("services") {
+		services = append(services, obj.(*corev1.Service).Name)
+	}
+	if len(services) != 2 || services[0] != "mysql" || services[1] != "db-client" {
+		t.Errorf("created services = %v, want [mysql db-client]", services)
+	}
+	statefulSets := f.Created("statefulsets")
+	if len(statefulSets) != 1 {
+		t.Fatalf("created %d statefulsets, want 1", len(statefulSets))
+	}
+	sts := statefulSets[0].(*appsv1.StatefulSet)
+	if sts.Name != "db-deployment" || sts.Namespace != "ns" {
+		t.Errorf("created statefulset %s/%s, want ns/db-deployment", sts.Namespace, sts.Name)
+	}
+	if image := sts.Spec.Template.Spec.Containers[0].Image; image != "arm64v8/mysql:8.0" {
+		t.Errorf("statefulset image = %s, want arm64v8/mysql:8.0", image)
+	}
+
+	got, err := f.CRClient.VolcV1alpha1().MySQLs("ns").Get(context.Background(), "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Finalizers) != 1 {
+		t.Errorf("finalizers = %v, want the cleanup finalizer", got.Finalizers)
+	}
+	if got.Status.ObservedGeneration != got.Generation {
+		t.Errorf("observedGeneration = %d, want %d", got.Status.ObservedGeneration, got.Generation)
+	}
+
+	// A second reconcile of the same generation changes nothing.
+	f.K8sClient.ClearActions()
+	if err := f.Reconcile(got); err != nil {
+		t.Fatalf("second Reconcile() = %v", err)
+	}
+	for _, resource := range []string{"secrets", "services", "statefulsets"} {
+		if created := f.Created(resource); len(created) != 0 {
+			t.Errorf("second reconcile created %d %s", len(created), resource)
+		}
+	}
+}
+
+func TestReconcileFailures(t *testing.T) {
+	failure := errors.New("injected")
+	for _, tc := range []struct {
+		name     string
+		verb     string
+		resource string
+		// created are the resources created before the failure.
+		created []string
+	}{
+		{name: "secret", verb: "create", resource: "secrets"},
+		{name: "config", verb: "create", resource: "configmaps", created: []string{"secrets"}},
+		{name: "service", verb: "create", resource: "services", created: []string{"secrets"}},
+		{name: "statefulset", verb: "create", resource: "statefulsets", created: []string{"secrets", "services"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+			if tc.resource == "configmaps" {
+				// The config ConfigMap is only created for a non-default
+				// configuration.
+				maxConnections := int32(500)
+				mysqlObj.Spec.Config.MaxConnections = &maxConnections
+			}
+			f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+			f.FailOn(tc.verb, tc.resource, failure)
+			if err := f.Reconcile(mysqlObj); !errors.Is(err, failure) {
+				t.Fatalf("Reconcile() = %v, want %v", err, failure)
+			}
+			for _, resource := range tc.created {
+				if len(f.Created(resource)) == 0 {
+					t.Errorf("no %s created before the failure", resource)
+				}
+			}
+			if created := f.Created("statefulsets"); tc.resource != "statefulsets" && len(created) != 0 {
+				t.Errorf("statefulset created after a failure of %s %s", tc.verb, tc.resource)
+			}
+			got, err := f.CRClient.VolcV1alpha1().MySQLs("ns").Get(context.Background(), "db", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status.ObservedGeneration != 0 {
+				t.Errorf("observedGeneration = %d after a failed reconcile, want 0", got.Status.ObservedGeneration)
+			}
+		})
+	}
+}
+
+func TestReconcileFinalizerFailure(t *testing.T) {
+	failure := errors.New("injected")
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	f.CRClient.PrependReactor("update", "mysqls", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return action.GetSubresource() == "", nil, failure
+	})
+	if err := f.Reconcile(mysqlObj); !errors.Is(err, failure) {
+		t.Fatalf("Reconcile() = %v, want %v", err, failure)
+	}
+	// Only the caches were filled, no child is created without the finalizer.
+	for _, action := range f.K8sClient.Actions() {
+		if action.GetVerb() != "list" {
+			t.Errorf("unexpected %s %s before the finalizer is set", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+func TestReconcileStatusFailure(t *testing.T) {
+	failure := errors.New("injected")
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	f.CRClient.PrependReactor("update", "mysqls", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return action.GetSubresource() == "status", nil, failure
+	})
+	if err := f.Reconcile(mysqlObj); !errors.Is(err, failure) {
+		t.Fatalf("Reconcile() = %v, want %v", err, failure)
+	}
+	if len(f.Created("statefulsets")) != 1 {
+		t.Errorf("statefulset not created before the status update")
+	}
+}
+
+func TestReconcileWaitsForTerminatingClaims(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	now := metav1.Now()
+	// The claim of a previous MySQL of the same name, still being deleted.
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "ns",
+			Name:              "mysql-store-db-deployment-0",
+			Labels:            controller.LabelsForInstance("db"),
+			DeletionTimestamp: &now,
+			Finalizers:        []string{"kubernetes.io/pvc-protection"},
+		},
+	}
+	f := ctrltesting.NewFixture([]runtime.Object{claim}, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	if created := f.Created("statefulsets"); len(created) != 0 {
+		t.Fatalf("statefulset created while a claim is terminating")
+	}
+
+	// Once the claim is gone, the requeued reconcile creates the StatefulSet.
+	err := f.K8sClient.CoreV1().PersistentVolumeClaims("ns").Delete(context.Background(), claim.Name, metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.CRClient.VolcV1alpha1().MySQLs("ns").Get(context.Background(), "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reconcile(got); err != nil {
+		t.Fatalf("second Reconcile() = %v", err)
+	}
+	if created := f.Created("statefulsets"); len(created) != 1 {
+		t.Errorf("created %d statefulsets after the claim is gone, want 1", len(created))
+	}
+}
+
+func TestReconcileGovernsStatefulSetWithHeadlessService(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	statefulSets := f.Created("statefulsets")
+	if len(statefulSets) != 1 {
+		t.Fatalf("created %d statefulsets, want 1", len(statefulSets))
+	}
+	serviceName := statefulSets[0].(*appsv1.StatefulSet).Spec.ServiceName
+	service, err := f.K8sClient.CoreV1().Services("ns").Get(context.Background(), serviceName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("service %q of the statefulset: %v", serviceName, err)
+	}
+	if service.Spec.ClusterIP != corev1.ClusterIPNone {
+		t.Errorf("service %q clusterIP = %q, want a headless service", serviceName, service.Spec.ClusterIP)
+	}
+}
+
+func TestReconcileWaitsForRunningReconcile(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.SyncCaches(); err != nil {
+		t.Fatal(err)
+	}
+	f.K8sClient.ClearActions()
+
+	// Another worker, or the add handler, is reconciling the same key.
+	unlock := f.Controller.LockKey("ns/db")
+	done := make(chan error)
+	go func() {
+		done <- f.Controller.Reconcile(context.Background(), "ns/db")
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Reconcile() = %v while the key is locked", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if actions := f.K8sClient.Actions(); len(actions) != 0 {
+		t.Errorf("%d actions while the key is locked, want none", len(actions))
+	}
+
+	unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Reconcile() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reconcile() still blocked after the key was unlocked")
+	}
+	if len(f.Created("statefulsets")) != 1 {
+		t.Errorf("statefulset not created once the key was unlocked")
+	}
+}
+
+func TestReconcileKey(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// others are the other MySQLs of the namespace.
+		others []string
+		// missing deletes the MySQL before the reconcile, as if its
+		// finalizer had been removed by hand.
+		missing bool
+		created map[string][]string
+		deleted map[string][]string
+		kept    map[string][]string
+	}{
+		{
+			name: "create",
+			created: map[string][]string{
+				"secrets":      {"mysql-password"},
+				"services":     {"mysql", "db-client"},
+				"statefulsets": {"db-deployment"},
+			},
+		},
+		{
+			name:    "missing last in namespace",
+			missing: true,
+			deleted: map[string][]string{
+				"secrets":      {"mysql-password", "db-connection"},
+				"services":     {"mysql", "db-client"},
+				"statefulsets": {"db-deployment"},
+			},
+		},
+		{
+			name:    "missing with other MySQLs",
+			others:  []string{"other"},
+			missing: true,
+			deleted: map[string][]string{
+				"secrets":      {"db-connection"},
+				"services":     {"db-client"},
+				"statefulsets": {"db-deployment"},
+			},
+			kept: map[string][]string{
+				"secrets":  {"mysql-password"},
+				"services": {"mysql"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+			crObjects := []runtime.Object{mysqlObj}
+			for _, name := range tc.others {
+				crObjects = append(crObjects, ctrltesting.NewMySQL("ns", name, "8.0"))
+			}
+			f := ctrltesting.NewFixture(nil, crObjects, controller.Options{})
+			if tc.missing {
+				if err := f.Reconcile(mysqlObj); err != nil {
+					t.Fatalf("Reconcile() = %v", err)
+				}
+				err := f.CRClient.VolcV1alpha1().MySQLs("ns").Delete(context.Background(), "db", metav1.DeleteOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.K8sClient.ClearActions()
+			}
+			if err := f.Reconcile(mysqlObj); err != nil {
+				t.Fatalf("Reconcile() = %v", err)
+			}
+
+			for resource, names := range tc.created {
+				created := map[string]bool{}
+				for _, obj := range f.Created(resource) {
+					created[obj.(metav1.Object).GetName()] = true
+				}
+				for _, name := range names {
+					if !created[name] {
+						t.Errorf("%s %s not created", resource, name)
+					}
+				}
+			}
+			for resource, names := range tc.deleted {
+				deleted := map[string]bool{}
+				for _, name := range f.Deleted(resource) {
+					deleted[name] = true
+				}
+				for _, name := range names {
+					if !deleted[name] {
+						t.Errorf("%s %s not deleted", resource, name)
+					}
+				}
+			}
+			for resource, names := range tc.kept {
+				for _, name := range names {
+					for _, deleted := range f.Deleted(resource) {
+						if deleted == name {
+							t.Errorf("%s %s deleted while other MySQLs use it", resource, name)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileChangesPort(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	mysqls := f.CRClient.VolcV1alpha1().MySQLs("ns")
+	got, err := mysqls.Get(context.Background(), "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Spec.Port = 3307
+	got.Generation++
+	if got, err = mysqls.Update(context.Background(), got, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reconcile(got); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	for _, name := range []string{"mysql", "db-client"} {
+		service, err := f.K8sClient.CoreV1().Services("ns").Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("service %s: %v", name, err)
+		}
+		if port := service.Spec.Ports[0].Port; port != 3307 {
+			t.Errorf("service %s port = %d, want 3307", name, port)
+		}
+	}
+	sts, err := f.K8sClient.AppsV1().StatefulSets("ns").Get(context.Background(), "db-deployment", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port := sts.Spec.Template.Spec.Containers[0].Ports[0].ContainerPort; port != 3307 {
+		t.Errorf("statefulset container port = %d, want 3307", port)
+	}
+}
+
+func TestReconcileWithoutStatusUpdates(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	mysqlObj.Spec.UpgradeStrategy = mysqlalpha1.UpgradeStrategyBlueGreen
+	f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{DisableStatusUpdates: true})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	// A blue/green upgrade and a restore are requested.
+	mysqls := f.CRClient.VolcV1alpha1().MySQLs("ns")
+	got, err := mysqls.Get(context.Background(), "db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Spec.Version = "8.1"
+	got.Generation++
+	if got, err = mysqls.Update(context.Background(), got, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	restore := &mysqlalpha1.MySQLRestore{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "restore"},
+		Spec:       mysqlalpha1.MySQLRestoreSpec{MySQLName: "db", ClaimName: "backups", File: "dump.sql"},
+	}
+	_, err = f.CRClient.VolcV1alpha1().MySQLRestores("ns").Create(context.Background(), restore, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.K8sClient.ClearActions()
+	f.CRClient.ClearActions()
+	if err := f.Reconcile(got); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	for _, action := range f.K8sClient.Actions() {
+		if action.GetVerb() == "create" || action.GetVerb() == "update" {
+			if resource := action.GetResource().Resource; resource == "statefulsets" || resource == "jobs" {
+				t.Errorf("%s %s while status updates are disabled", action.GetVerb(), resource)
+			}
+		}
+	}
+	for _, action := range f.CRClient.Actions() {
+		if action.GetSubresource() == "status" {
+			t.Errorf("%s status of %s while status updates are disabled", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+func TestReconcileRecreatesDeletedChildren(t *testing.T) {
+	for _, tc := range []struct {
+		resource string
+		name     string
+		delete   func(f *ctrltesting.Fixture) error
+	}{
+		{
+			resource: "secrets",
+			name:     "mysql-password",
+			delete: func(f *ctrltesting.Fixture) error {
+				return f.K8sClient.CoreV1().Secrets("ns").Delete(context.Background(), "mysql-password", metav1.DeleteOptions{})
+			},
+		},
+		{
+			resource: "services",
+			name:     "mysql",
+			delete: func(f *ctrltesting.Fixture) error {
+				return f.K8sClient.CoreV1().Services("ns").Delete(context.Background(), "mysql", metav1.DeleteOptions{})
+			},
+		},
+		{
+			resource: "services",
+			name:     "db-client",
+			delete: func(f *ctrltesting.Fixture) error {
+				return f.K8sClient.CoreV1().Services("ns").Delete(context.Background(), "db-client", metav1.DeleteOptions{})
+			},
+		},
+		{
+			resource: "statefulsets",
+			name:     "db-deployment",
+			delete: func(f *ctrltesting.Fixture) error {
+				return f.K8sClient.AppsV1().StatefulSets("ns").Delete(context.Background(), "db-deployment", metav1.DeleteOptions{})
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+			f := ctrltesting.NewFixture(nil, []runtime.Object{mysqlObj}, controller.Options{})
+			if err := f.Reconcile(mysqlObj); err != nil {
+				t.Fatalf("Reconcile() = %v", err)
+			}
+			got, err := f.CRClient.VolcV1alpha1().MySQLs("ns").Get(context.Background(), "db", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tc.delete(f); err != nil {
+				t.Fatal(err)
+			}
+			f.K8sClient.ClearActions()
+			// The spec did not change, the deleted child is noticed from the
+			// informer caches.
+			if err := f.Reconcile(got); err != nil {
+				t.Fatalf("Reconcile() = %v", err)
+			}
+			var created []string
+			for _, obj := range f.Created(tc.resource) {
+				created = append(created, obj.(metav1.Object).GetName())
+			}
+			if len(created) != 1 || created[0] != tc.name {
+				t.Errorf("created %s %v, want %s", tc.resource, created, tc.name)
+			}
+		})
+	}
+}
+
+func TestReconcileLabelsConnectionSecret(t *testing.T) {
+	mysqlObj := ctrltesting.NewMySQL("ns", "db", "8.0")
+	mysqlObj.Spec.ConnectionSecret = true
+	// A connection Secret created before it was labelled.
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "db-connection"},
+	}
+	f := ctrltesting.NewFixture([]runtime.Object{secret}, []runtime.Object{mysqlObj}, controller.Options{})
+	if err := f.Reconcile(mysqlObj); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+
+	got, err := f.K8sClient.CoreV1().Secrets("ns").Get(context.Background(), "db-connection", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !controller.SelectorForInstance("db").Matches(labels.Set(got.Labels)) {
+		t.Errorf("connection secret labels = %v, want the labels of the instance", got.Labels)
+	}
+}
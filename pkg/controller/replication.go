@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	exporterScrapeTimeout = 5 * time.Second
+	// replicationLagMetric is reported by the exporter on replicas only.
+	replicationLagMetric = "mysql_slave_status_seconds_behind_master"
+)
+
+// replicated reports whether the replication of the MySQL can be observed,
+// which takes several replicas and the exporter sidecar.
+func replicated(mysqlObj *mysqlalpha1.MySQL) bool {
+	return specReplicas(mysqlObj) > 1 && mysqlObj.Spec.Metrics.Enabled
+}
+
+// syncReplication records the lag of every replica in the status. Pods whose
+// exporter cannot be scraped are left out.
+func (c *Controller) syncReplication(mysqlObj *mysqlalpha1.MySQL, pods []*corev1.Pod) {
+	if !replicated(mysqlObj) {
+		return
+	}
+
+	for _, pod := range pods {
+		lag, ok, err := c.replicationLag(pod)
+		if err != nil {
+			klog.ErrorS(err, "Failed to scrape replication lag", "namespace", pod.Namespace, "name", pod.Name)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		mysqlObj.Status.Replicas = append(mysqlObj.Status.Replicas, mysqlalpha1.MySQLReplicaStatus{
+			Name:       pod.Name,
+			LagSeconds: int64(math.Ceil(lag)),
+		})
+	}
+	if len(mysqlObj.Status.Replicas) == 0 {
+		return
+	}
+
+	// The lister returns the pods in no particular order.
+	sort.Slice(mysqlObj.Status.Replicas, func(i, j int) bool {
+		return mysqlObj.Status.Replicas[i].Name < mysqlObj.Status.Replicas[j].Name
+	})
+	var highest int64
+	for _, replica := range mysqlObj.Status.Replicas {
+		if replica.LagSeconds > highest {
+			highest = replica.LagSeconds
+		}
+	}
+	mysqlObj.Status.MaxReplicationLagSeconds = &highest
+}
+
+// replicationLagged returns the reason and message of a replication lag above
+// maxLag, or an empty reason.
+func replicationLagged(mysqlObj *mysqlalpha1.MySQL, maxLag time.Duration) (string, string) {
+	if maxLag <= 0 || mysqlObj.Status.MaxReplicationLagSeconds == nil {
+		return "", ""
+	}
+	for _, replica := range mysqlObj.Status.Replicas {
+		if time.Duration(replica.LagSeconds)*time.Second > maxLag {
+			return reasonReplicationLag, fmt.Sprintf("Pod %s lags %ds behind its source, above %s", replica.Name, replica.LagSeconds, maxLag)
+		}
+	}
+	return "", ""
+}
+
+// replicationLag reads the replication lag of a pod from its exporter sidecar.
+// It reports false when the pod is not a replica.
+func (c *Controller) replicationLag(pod *corev1.Pod) (float64, bool, error) {
+	if pod.Status.PodIP == "" {
+		return 0, false, nil
+	}
+	resp, err := c.httpClient.Get(fmt.Sprintf("http://%s:%d/metrics", pod.Status.PodIP, exporterPort))
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, fmt.Errorf("exporter returned %s", resp.Status)
+	}
+
+	families, err := new(expfmt.TextParser).TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return 0, false, err
+	}
+	family, ok := families[replicationLagMetric]
+	if !ok || len(family.GetMetric()) == 0 {
+		return 0, false, nil
+	}
+	var lag float64
+	for i, metric := range family.GetMetric() {
+		// The exporter may expose it untyped.
+		value := metric.GetUntyped().GetValue()
+		if metric.Gauge != nil {
+			value = metric.GetGauge().GetValue()
+		}
+		// A replica of several sources lags as much as its slowest channel.
+		if i == 0 || value > lag {
+			lag = value
+		}
+	}
+	return lag, true, nil
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// syncResources records the managed Secret, Services and StatefulSets in the
+// status, so the objects of an instance can be told apart.
+func (c *Controller) syncResources(mysqlObj *mysqlalpha1.MySQL) error {
+	_, err := c.secretLister.Secrets(mysqlObj.Namespace).Get(secretName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	secret := mysqlalpha1.MySQLResourceStatus{Kind: "Secret", Name: secretName, Ready: err == nil}
+
+	mysqlObj.Status.Resources = []mysqlalpha1.MySQLResourceStatus{secret}
+	for _, name := range []string{headlessServiceName(mysqlObj), clientServiceName(mysqlObj)} {
+		_, err = c.serviceLister.Services(mysqlObj.Namespace).Get(name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		service := mysqlalpha1.MySQLResourceStatus{Kind: "Service", Name: name, Ready: err == nil}
+		mysqlObj.Status.Resources = append(mysqlObj.Status.Resources, service)
+	}
+	for _, name := range []string{statefulSetName(mysqlObj), candidateStatefulSetName(mysqlObj)} {
+		if name == "" {
+			continue
+		}
+		sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		statefulSet := mysqlalpha1.MySQLResourceStatus{Kind: "StatefulSet", Name: name}
+		if err == nil {
+			reason, _, err := statefulSetReadiness{}.check(mysqlObj, sts, nil)
+			if err != nil {
+				return err
+			}
+			statefulSet.Ready = reason == ""
+		}
+		mysqlObj.Status.Resources = append(mysqlObj.Status.Resources, statefulSet)
+	}
+	return nil
+}
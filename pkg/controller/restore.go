@@ -0,0 +1,303 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var restoreContainerName = "restore"
+
+// syncRestore moves the restores of a MySQL forward, one at a time in
+// creation order. The MySQL is held at zero pods from ScalingDown until the
+// dump is loaded, see desiredReplicas.
+func (c *Controller) syncRestore(mysqlObj *mysqlalpha1.MySQL) error {
+	// A restore advances through its status, it would scale the MySQL down
+	// again on every reconcile.
+	if c.options.DisableStatusUpdates {
+		return nil
+	}
+	restores, err := c.restoreLister.MySQLRestores(mysqlObj.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	var active *mysqlalpha1.MySQLRestore
+	var queued []*mysqlalpha1.MySQLRestore
+	for _, restore := range restores {
+		if restore.Spec.MySQLName != mysqlObj.Name {
+			continue
+		}
+		switch restore.Status.Phase {
+		case mysqlalpha1.RestorePhaseSucceeded, mysqlalpha1.RestorePhaseFailed:
+		case mysqlalpha1.RestorePhaseScalingDown, mysqlalpha1.RestorePhaseRestoring, mysqlalpha1.RestorePhaseScalingUp:
+			active = restore
+		default:
+			queued = append(queued, restore)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		if !queued[i].CreationTimestamp.Equal(&queued[j].CreationTimestamp) {
+			return queued[i].CreationTimestamp.Before(&queued[j].CreationTimestamp)
+		}
+		return queued[i].Name < queued[j].Name
+	})
+	if active == nil && len(queued) > 0 && candidateStatefulSetName(mysqlObj) == "" {
+		active, queued = queued[0], queued[1:]
+	}
+	for _, restore := range queued {
+		if restore.Status.Phase == "" {
+			ret := restore.DeepCopy()
+			ret.Status.Phase = mysqlalpha1.RestorePhasePending
+			if err := c.updateRestoreStatus(restore, ret); err != nil {
+				return err
+			}
+		}
+	}
+
+	mysqlObj.Status.Restore = ""
+	if active == nil {
+		return nil
+	}
+	ret := active.DeepCopy()
+	err = c.restore(mysqlObj, ret)
+	if ret.Status.Phase == mysqlalpha1.RestorePhaseScalingDown || ret.Status.Phase == mysqlalpha1.RestorePhaseRestoring {
+		mysqlObj.Status.Restore = ret.Name
+	}
+	if updateErr := c.updateRestoreStatus(active, ret); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+// restore advances a restore by one phase when its current one is done.
+func (c *Controller) restore(mysqlObj *mysqlalpha1.MySQL, restore *mysqlalpha1.MySQLRestore) error {
+	switch restore.Status.Phase {
+	case "", mysqlalpha1.RestorePhasePending:
+		if message := restoreRefused(mysqlObj, restore); message != "" {
+			finishRestore(restore, mysqlalpha1.RestorePhaseFailed, message)
+			return nil
+		}
+		now := metav1.Now()
+		restore.Status.StartTime = &now
+		restore.Status.Phase = mysqlalpha1.RestorePhaseScalingDown
+		klog.InfoS("Scale down for restore.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "restore", restore.Name)
+		c.enqueueAfter(mysqlObj, statefulSetPollInterval)
+		return nil
+
+	case mysqlalpha1.RestorePhaseScalingDown:
+		sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(statefulSetName(mysqlObj))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if sts != nil {
+			pods, err := c.listPods(sts)
+			if err != nil {
+				return err
+			}
+			if len(pods) > 0 || sts.Status.Replicas > 0 {
+				c.enqueueAfter(mysqlObj, statefulSetPollInterval)
+				return nil
+			}
+		}
+		for i := int32(0); i < specReplicas(mysqlObj); i++ {
+			claim := restoreDataClaimName(mysqlObj, i)
+			if _, err := c.claimLister.PersistentVolumeClaims(mysqlObj.Namespace).Get(claim); apierrors.IsNotFound(err) {
+				finishRestore(restore, mysqlalpha1.RestorePhaseFailed, fmt.Sprintf("Data claim %s does not exist", claim))
+				return nil
+			} else if err != nil {
+				return err
+			}
+			job := newRestoreJob(mysqlObj, restore, i)
+			_, err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Create(context.Background(), job, metav1.CreateOptions{})
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				return err
+			}
+			klog.InfoS("Create restore job.", "namespace", job.Namespace, "name", job.Name, "claim", claim)
+		}
+		restore.Status.Phase = mysqlalpha1.RestorePhaseRestoring
+		c.enqueueAfter(mysqlObj, backupPollInterval)
+		return nil
+
+	case mysqlalpha1.RestorePhaseRestoring:
+		for i := int32(0); i < specReplicas(mysqlObj); i++ {
+			name := restoreJobName(restore, i)
+			job, err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				finishRestore(restore, mysqlalpha1.RestorePhaseFailed, fmt.Sprintf("Job %s is gone", name))
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			switch jobFinished(job) {
+			case batchv1.JobFailed:
+				finishRestore(restore, mysqlalpha1.RestorePhaseFailed, fmt.Sprintf("Job %s failed", name))
+				return nil
+			case "":
+				c.enqueueAfter(mysqlObj, backupPollInterval)
+				return nil
+			}
+		}
+		restore.Status.Phase = mysqlalpha1.RestorePhaseScalingUp
+		klog.InfoS("Scale up after restore.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "restore", restore.Name)
+		c.enqueueAfter(mysqlObj, statefulSetPollInterval)
+		return nil
+
+	case mysqlalpha1.RestorePhaseScalingUp:
+		sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(statefulSetName(mysqlObj))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if sts == nil || sts.Status.ReadyReplicas < desiredReplicas(mysqlObj) {
+			c.enqueueAfter(mysqlObj, statefulSetPollInterval)
+			return nil
+		}
+		finishRestore(restore, mysqlalpha1.RestorePhaseSucceeded, "")
+		klog.InfoS("Finish restore.", "namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "restore", restore.Name)
+	}
+	return nil
+}
+
+// restoreRefused explains why a restore cannot run, or returns an empty
+// string.
+func restoreRefused(mysqlObj *mysqlalpha1.MySQL, restore *mysqlalpha1.MySQLRestore) string {
+	switch {
+	case mysqlObj.Spec.Ephemeral:
+		return "An ephemeral MySQL has no data claims to restore into"
+	case restoreClaimName(mysqlObj, restore) == "":
+		return "Neither spec.claimName nor spec.backup.claimName of the MySQL is set"
+	case restore.Spec.File == "":
+		return "spec.file is not set"
+	}
+	return ""
+}
+
+func finishRestore(restore *mysqlalpha1.MySQLRestore, phase mysqlalpha1.RestorePhase, message string) {
+	now := metav1.Now()
+	restore.Status.Phase = phase
+	restore.Status.Message = message
+	restore.Status.CompletionTime = &now
+}
+
+func (c *Controller) updateRestoreStatus(old, restore *mysqlalpha1.MySQLRestore) error {
+	if equality.Semantic.DeepEqual(old.Status, restore.Status) {
+		return nil
+	}
+	if c.options.DisableStatusUpdates {
+		klog.V(2).InfoS("Skip restore status update.", "namespace", restore.Namespace, "name", restore.Name, "status", restore.Status)
+		return nil
+	}
+	_, err := c.crClient.VolcV1alpha1().MySQLRestores(restore.Namespace).UpdateStatus(context.TODO(), restore, metav1.UpdateOptions{})
+	return err
+}
+
+// handleRestore enqueues the MySQL targeted by a MySQLRestore.
+func (c *Controller) handleRestore(obj interface{}) {
+	restore, ok := obj.(*mysqlalpha1.MySQLRestore)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("Failed to type assert object: %v", obj)
+			return
+		}
+		restore, ok = tombstone.Obj.(*mysqlalpha1.MySQLRestore)
+		if !ok {
+			klog.Errorf("Failed to type assert tombstone object: %v", tombstone.Obj)
+			return
+		}
+	}
+	mysqlObj, err := c.crLister.MySQLs(restore.Namespace).Get(restore.Spec.MySQLName)
+	if err != nil {
+		return
+	}
+	c.enqueue(mysqlObj)
+}
+
+func restoreClaimName(mysqlObj *mysqlalpha1.MySQL, restore *mysqlalpha1.MySQLRestore) string {
+	if restore.Spec.ClaimName != "" {
+		return restore.Spec.ClaimName
+	}
+	return mysqlObj.Spec.Backup.ClaimName
+}
+
+func restoreDataClaimName(mysqlObj *mysqlalpha1.MySQL, ordinal int32) string {
+	return fmt.Sprintf("%s-%s-%d", volumeMountName, statefulSetName(mysqlObj), ordinal)
+}
+
+func restoreJobName(restore *mysqlalpha1.MySQLRestore, ordinal int32) string {
+	return fmt.Sprintf("%s-restore-%d", restore.Name, ordinal)
+}
+
+// newRestoreJob loads the dump into the data claim of a pod. The MySQL is
+// scaled down, so the Job runs its own server on the claim, without
+// networking, for the duration of the load.
+func newRestoreJob(mysqlObj *mysqlalpha1.MySQL, restore *mysqlalpha1.MySQLRestore, ordinal int32) *batchv1.Job {
+	f := flavorOf(mysqlObj)
+	script := fmt.Sprintf(`docker-entrypoint.sh %[7]s --skip-networking --datadir=%[4]s &
+until %[5]s -uroot -p"$%[1]s" ping --silent; do sleep 1; done
+%[6]s -uroot -p"$%[1]s" < %[2]s/%[3]s
+status=$?
+%[5]s -uroot -p"$%[1]s" shutdown
+wait
+exit $status`, f.passwordEnv, backupMountPath, restore.Spec.File, mysqlObj.Spec.DataDir, f.admin, f.client, f.server)
+
+	spec := newBackupJobSpec(mysqlObj, script)
+	container := &spec.Template.Spec.Containers[0]
+	container.Name = restoreContainerName
+	container.Env = mysqlEnv(mysqlObj)
+	container.VolumeMounts = []corev1.VolumeMount{
+		{
+			Name:      volumeMountName,
+			MountPath: mysqlObj.Spec.DataDir,
+			SubPath:   mysqlObj.Spec.DataSubPath,
+		},
+		{
+			Name:      backupVolumeName,
+			MountPath: backupMountPath,
+			ReadOnly:  true,
+		},
+	}
+	spec.Template.Spec.SecurityContext = &corev1.PodSecurityContext{
+		FSGroup: &mysqlGroupID,
+	}
+	spec.Template.Spec.Volumes = []corev1.Volume{
+		{
+			Name: volumeMountName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: restoreDataClaimName(mysqlObj, ordinal),
+				},
+			},
+		},
+		{
+			Name: backupVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: restoreClaimName(mysqlObj, restore),
+					ReadOnly:  true,
+				},
+			},
+		},
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      restoreJobName(restore, ordinal),
+			Namespace: restore.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(restore, mysqlalpha1.SchemeGroupVersion.WithKind("MySQLRestore")),
+			},
+		},
+		Spec: spec,
+	}
+}
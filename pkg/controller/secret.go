@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// syncSecret creates the root password Secret of the namespace. An existing
+// Secret is left as is, the password only initializes the data directory.
+func (c *Controller) syncSecret(mysqlObj *mysqlalpha1.MySQL) error {
+	_, err := c.secretLister.Secrets(mysqlObj.Namespace).Get(secretName)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+	_, err = c.k8sClient.CoreV1().Secrets(mysqlObj.Namespace).Create(context.Background(), newSecret(), metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Create secret.", "namespace", mysqlObj.Namespace, "name", secretName)
+	return nil
+}
+
+// secretUpToDate reports whether the root password Secret exists, so a deleted
+// one is created again.
+func (c *Controller) secretUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	_, err := c.secretLister.Secrets(mysqlObj.Namespace).Get(secretName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func newSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   secretName,
+			Labels: sharedLabels(),
+			Annotations: map[string]string{
+				passwordHashAnnotation: hashObject(passwd),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			envName: []byte(passwd),
+		},
+	}
+}
@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// syncServices converges the two Services of a MySQL. The headless Service
+// governs the StatefulSet and publishes every pod, ready or not, so pods find
+// their peers while bootstrapping replication. Clients go through the client
+// Service, which only routes to ready pods.
+func (c *Controller) syncServices(mysqlObj *mysqlalpha1.MySQL) error {
+	// Older headless Services did not publish pods that are not ready.
+	headless, err := c.serviceLister.Services(mysqlObj.Namespace).Get(headlessServiceName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		_, err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Create(context.Background(), newHeadlessService(mysqlObj), metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create headless service.", "namespace", mysqlObj.Namespace, "name", headlessServiceName(mysqlObj))
+	} else if err != nil {
+		return err
+	} else {
+		ret := headless.DeepCopy()
+		ret.Spec.PublishNotReadyAddresses = true
+		// The port of a Service shared with other MySQLs is left alone,
+		// the DNS records of the pods do not depend on it.
+		portChanged := c.lastInNamespace(mysqlObj) && setServicePort(ret, mysqlPort(mysqlObj))
+		if !headless.Spec.PublishNotReadyAddresses || portChanged {
+			_, err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+			if err != nil {
+				return err
+			}
+			klog.InfoS("Update headless service.", "namespace", ret.Namespace, "name", ret.Name)
+		}
+	}
+	return c.syncClientService(mysqlObj)
+}
+
+// syncClientService creates the client Service or updates its annotations and
+// ports.
+// Its selector is left to selectStatefulSet once created.
+func (c *Controller) syncClientService(mysqlObj *mysqlalpha1.MySQL) error {
+	name := clientServiceName(mysqlObj)
+	desired := newClientService(mysqlObj)
+	current, err := c.serviceLister.Services(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		_, err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create client service.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(current.Annotations, desired.Annotations) && !current.Spec.PublishNotReadyAddresses &&
+		equality.Semantic.DeepEqual(current.Spec.Ports, desired.Spec.Ports) {
+		return nil
+	}
+	ret := current.DeepCopy()
+	ret.Annotations = desired.Annotations
+	ret.Spec.Ports = desired.Spec.Ports
+	ret.Spec.PublishNotReadyAddresses = false
+	_, err = c.k8sClient.CoreV1().Services(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update client service.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// servicesUpToDate reports whether both Services exist, so a deleted one is
+// created again. Their content is left to syncServices.
+func (c *Controller) servicesUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	for _, name := range []string{headlessServiceName(mysqlObj), clientServiceName(mysqlObj)} {
+		_, err := c.serviceLister.Services(mysqlObj.Namespace).Get(name)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// newHeadlessService returns the Service governing the StatefulSet. Its
+// annotations are only set on creation, and its port only follows spec.port
+// while a single MySQL uses it: it is shared by the MySQLs of the namespace,
+// and only serves the DNS records of the pods.
+func newHeadlessService(mysqlObj *mysqlalpha1.MySQL) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        headlessServiceName(mysqlObj),
+			Labels:      sharedLabels(),
+			Annotations: mergeAnnotations(mysqlObj.Spec.Service.Annotations, nil),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       portName,
+					Port:       mysqlPort(mysqlObj),
+					TargetPort: intstr.FromString(portName),
+				},
+			},
+			ClusterIP: "None",
+			// Pods resolve their peers before they are ready, clients go
+			// through the client Service instead.
+			PublishNotReadyAddresses: true,
+			Selector:                 sharedLabels(),
+		},
+	}
+}
+
+// setServicePort sets the port of the Service exposing MySQL, and reports
+// whether it changed. The target port is the named container port, which
+// follows the pods.
+func setServicePort(service *corev1.Service, port int32) bool {
+	for i := range service.Spec.Ports {
+		if service.Spec.Ports[i].Name == portName && service.Spec.Ports[i].Port != port {
+			service.Spec.Ports[i].Port = port
+			return true
+		}
+	}
+	return false
+}
+
+func clientServiceName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-client"
+}
+
+// clientSelector selects the pods of the MySQL, and only those of the named
+// StatefulSet when name is not empty.
+func clientSelector(mysqlObj *mysqlalpha1.MySQL, name string) map[string]string {
+	selector := LabelsForInstance(mysqlObj.Name)
+	if name != "" {
+		selector[statefulSetLabelKey] = name
+	}
+	return selector
+}
+
+// clientServicePorts returns the MySQL port, the X Protocol port when enabled
+// and spec.additionalServicePorts, with the defaults of the API server filled
+// in so they compare to those of the existing Service.
+func clientServicePorts(mysqlObj *mysqlalpha1.MySQL) []corev1.ServicePort {
+	ports := []corev1.ServicePort{
+		{
+			Name:       portName,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       mysqlPort(mysqlObj),
+			TargetPort: intstr.FromString(portName),
+		},
+	}
+	if mysqlObj.Spec.XProtocol.Enabled {
+		ports = append(ports, corev1.ServicePort{
+			Name:       xProtocolPortName,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       xProtocolPort,
+			TargetPort: intstr.FromString(xProtocolPortName),
+		})
+	}
+	for _, port := range mysqlObj.Spec.AdditionalServicePorts {
+		port := *port.DeepCopy()
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if port.TargetPort.IntVal == 0 && port.TargetPort.StrVal == "" {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+func newClientService(mysqlObj *mysqlalpha1.MySQL) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        clientServiceName(mysqlObj),
+			Namespace:   mysqlObj.Namespace,
+			Labels:      LabelsForInstance(mysqlObj.Name),
+			Annotations: mergeAnnotations(mysqlObj.Spec.Service.Annotations, nil),
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports:    clientServicePorts(mysqlObj),
+			Selector: clientSelector(mysqlObj, ""),
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	startupScriptKey        = "zz-operator.sh"
+	startupScriptVolumeName = "mysql-startup-script"
+	startupScriptMountPath  = "/docker-entrypoint-initdb.d/" + startupScriptKey
+	startupScriptMode       = int32(0755)
+)
+
+// syncStartupScript converges the ConfigMap holding spec.startupScript, or
+// removes it when the spec sets none. The script is run by the image
+// entrypoint when it initializes an empty data directory, after the other
+// init files as its name sorts last. Changing it does not roll the pods.
+func (c *Controller) syncStartupScript(mysqlObj *mysqlalpha1.MySQL) error {
+	name := startupScriptName(mysqlObj)
+	current, err := c.configMapLister.ConfigMaps(mysqlObj.Namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+
+	script := mysqlObj.Spec.StartupScript
+	if script == "" {
+		if current == nil {
+			return nil
+		}
+		err = c.k8sClient.CoreV1().ConfigMaps(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.InfoS("Delete startup script configmap.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+
+	data := map[string]string{
+		startupScriptKey: script,
+	}
+	if current == nil {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: mysqlObj.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*newOwnerRef(mysqlObj),
+				},
+			},
+			Data: data,
+		}
+		_, err = c.k8sClient.CoreV1().ConfigMaps(mysqlObj.Namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.InfoS("Create startup script configmap.", "namespace", mysqlObj.Namespace, "name", name)
+		return nil
+	}
+	if current.Data[startupScriptKey] == script {
+		return nil
+	}
+	ret := current.DeepCopy()
+	ret.Data = data
+	_, err = c.k8sClient.CoreV1().ConfigMaps(mysqlObj.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update startup script configmap.", "namespace", mysqlObj.Namespace, "name", name)
+	return nil
+}
+
+// startupScriptUpToDate reports whether the startup script ConfigMap matches
+// the spec.
+func (c *Controller) startupScriptUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	current, err := c.configMapLister.ConfigMaps(mysqlObj.Namespace).Get(startupScriptName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		return mysqlObj.Spec.StartupScript == "", nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mysqlObj.Spec.StartupScript != "" && current.Data[startupScriptKey] == mysqlObj.Spec.StartupScript, nil
+}
+
+func startupScriptName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-startup-script"
+}
+
+// startupScriptVolume returns the volume of the startup script, executable so
+// the entrypoint runs it instead of sourcing it.
+func startupScriptVolume(mysqlObj *mysqlalpha1.MySQL) corev1.Volume {
+	return corev1.Volume{
+		Name: startupScriptVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: startupScriptName(mysqlObj),
+				},
+				DefaultMode: &startupScriptMode,
+			},
+		},
+	}
+}
@@ -0,0 +1,537 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+	"github.com/cyhw/mysql-operator/pkg/validation"
+)
+
+var claimPollInterval = 5 * time.Second
+
+// syncStatefulSet creates the StatefulSet or rolls it to the desired pod
+// template and number of replicas, and records whether the MySQL is
+// suspended. An invalid image, a password Secret without its key or a refused
+// downgrade is left to syncStatus to report rather than rolled out.
+func (c *Controller) syncStatefulSet(mysqlObj *mysqlalpha1.MySQL) error {
+	setSuspended(mysqlObj)
+	if err := c.labelClaims(mysqlObj); err != nil {
+		return err
+	}
+
+	sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(statefulSetName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		sts = nil
+	} else if err != nil {
+		return err
+	}
+	if err := validation.ValidateImage(mysqlImage(mysqlObj)); err != nil {
+		return nil
+	}
+	if message, err := c.secretKeyMissing(mysqlObj.Namespace); err != nil || message != "" {
+		return err
+	}
+	if c.tooManyReplicas(mysqlObj) != "" {
+		return nil
+	}
+	if _, refused := refusedDowngrade(mysqlObj, sts); refused {
+		return nil
+	}
+	if ok, err := c.syncUpgradeBackup(mysqlObj, sts); err != nil || !ok {
+		return err
+	}
+
+	checksum, err := c.configChecksum(mysqlObj)
+	if err != nil {
+		return err
+	}
+	if sts != nil {
+		handled, err := c.syncBlueGreen(mysqlObj, sts, checksum)
+		if err != nil || handled {
+			return err
+		}
+	}
+	desired := newStatefulSet(mysqlObj, statefulSetName(mysqlObj), checksum)
+	if sts == nil {
+		return c.createStatefulSet(mysqlObj, desired)
+	}
+	return c.updateStatefulSet(sts, desired)
+}
+
+// updateStatefulSet updates the mutable fields of the StatefulSet to the
+// desired ones when its spec hash differs.
+func (c *Controller) updateStatefulSet(sts, desired *v1.StatefulSet) error {
+	if sts.Annotations[specHashAnnotation] == desired.Annotations[specHashAnnotation] {
+		return nil
+	}
+	// The selector, service name, pod management policy and volume claim
+	// templates are immutable.
+	ret := sts.DeepCopy()
+	if ret.Annotations == nil {
+		ret.Annotations = map[string]string{}
+	}
+	ret.Annotations[specHashAnnotation] = desired.Annotations[specHashAnnotation]
+	ret.Spec.Replicas = desired.Spec.Replicas
+	ret.Spec.MinReadySeconds = desired.Spec.MinReadySeconds
+	ret.Spec.RevisionHistoryLimit = desired.Spec.RevisionHistoryLimit
+	ret.Spec.PersistentVolumeClaimRetentionPolicy = desired.Spec.PersistentVolumeClaimRetentionPolicy
+	ret.Spec.Template = desired.Spec.Template
+	_, err := c.k8sClient.AppsV1().StatefulSets(ret.Namespace).Update(context.Background(), ret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Update statefulset.", "namespace", ret.Namespace, "name", ret.Name, "replicas", *ret.Spec.Replicas)
+	return nil
+}
+
+// createStatefulSet creates the StatefulSet once the claims left by a deleted
+// MySQL of the same name are gone. The StatefulSet controller fails to start
+// pods on claims that are being deleted, so it would otherwise wait for the
+// pods to be deleted by hand. A StatefulSet the lister did not see yet, such
+// as one created before a restart of the operator, is updated instead.
+func (c *Controller) createStatefulSet(mysqlObj *mysqlalpha1.MySQL, sts *v1.StatefulSet) error {
+	claims, err := c.claimLister.PersistentVolumeClaims(mysqlObj.Namespace).List(SelectorForInstance(mysqlObj.Name))
+	if err != nil {
+		return err
+	}
+	for _, claim := range claims {
+		if claim.DeletionTimestamp != nil && isClaimOfMySQL(mysqlObj, claim.Name) {
+			klog.InfoS("Wait for PVC deletion.", "namespace", claim.Namespace, "name", claim.Name)
+			c.enqueueAfter(mysqlObj, claimPollInterval)
+			return nil
+		}
+	}
+
+	_, err = c.k8sClient.AppsV1().StatefulSets(sts.Namespace).Create(context.Background(), sts, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		current, err := c.k8sClient.AppsV1().StatefulSets(sts.Namespace).Get(context.Background(), sts.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		return c.updateStatefulSet(current, sts)
+	}
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Create statefulset.", "namespace", sts.Namespace, "name", sts.Name)
+	return nil
+}
+
+// statefulSetUpToDate reports whether the StatefulSet matches the spec.
+func (c *Controller) statefulSetUpToDate(mysqlObj *mysqlalpha1.MySQL) (bool, error) {
+	if candidateStatefulSetName(mysqlObj) != "" {
+		return false, nil
+	}
+	sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(statefulSetName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	checksum, err := c.configChecksum(mysqlObj)
+	if err != nil {
+		return false, err
+	}
+	return sts.Annotations[specHashAnnotation] == newStatefulSet(mysqlObj, sts.Name, checksum).Annotations[specHashAnnotation], nil
+}
+
+// configChecksum returns a checksum of the config mounted in the pods, or an
+// empty string when there is none. A missing ConfigMap counts as empty: the
+// pods do not start until it is created.
+func (c *Controller) configChecksum(mysqlObj *mysqlalpha1.MySQL) (string, error) {
+	config := renderConfig(mysqlObj)
+	if mysqlObj.Spec.ConfigMapName == "" {
+		if config == "" {
+			return "", nil
+		}
+		return hashObject(config), nil
+	}
+	configMap, err := c.configMapLister.ConfigMaps(mysqlObj.Namespace).Get(mysqlObj.Spec.ConfigMapName)
+	if apierrors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{}
+	} else if err != nil {
+		return "", err
+	}
+	return hashObject([]interface{}{config, configMap.Data, configMap.BinaryData}), nil
+}
+
+func newStatefulSet(mysqlObj *mysqlalpha1.MySQL, name, checksum string) *v1.StatefulSet {
+	desired := desiredReplicas(mysqlObj)
+	template := newPodTemplate(mysqlObj, checksum)
+	if blueGreen(mysqlObj) || name == candidateStatefulSetName(mysqlObj) {
+		blueGreenPodTemplate(mysqlObj, name, &template)
+	}
+	spec := v1.StatefulSetSpec{
+		// The selector is immutable, so it stays the one shared by the
+		// sets created before the pods carried the instance label. The
+		// StatefulSet controller tells the pods of the sets apart by their
+		// names and owner references.
+		Selector: &metav1.LabelSelector{
+			MatchLabels: sharedLabels(),
+		},
+		ServiceName:                          headlessServiceName(mysqlObj),
+		Replicas:                             &desired,
+		PodManagementPolicy:                  mysqlObj.Spec.PodManagementPolicy,
+		MinReadySeconds:                      mysqlObj.Spec.MinReadySeconds,
+		RevisionHistoryLimit:                 mysqlObj.Spec.RevisionHistoryLimit,
+		Template:                             template,
+		VolumeClaimTemplates:                 volumeClaimTemplates(mysqlObj),
+		PersistentVolumeClaimRetentionPolicy: mysqlObj.Spec.PVCRetentionPolicy,
+	}
+	return &v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: mysqlObj.Namespace,
+			Annotations: map[string]string{
+				specHashAnnotation: hashObject(spec),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: spec,
+	}
+}
+
+func newPodTemplate(mysqlObj *mysqlalpha1.MySQL, checksum string) corev1.PodTemplateSpec {
+	podTemplate := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      podLabels(mysqlObj),
+			Annotations: podAnnotations(mysqlObj, checksum),
+		},
+		Spec: corev1.PodSpec{
+			TerminationGracePeriodSeconds: terminationGracePeriod(mysqlObj),
+			PriorityClassName:             mysqlObj.Spec.PriorityClassName,
+			RuntimeClassName:              mysqlObj.Spec.RuntimeClassName,
+			Overhead:                      mysqlObj.Spec.PodOverhead,
+			SchedulerName:                 mysqlObj.Spec.SchedulerName,
+			TopologySpreadConstraints:     topologySpreadConstraints(mysqlObj, specReplicas(mysqlObj)),
+			HostAliases:                   mysqlObj.Spec.HostAliases,
+			ReadinessGates:                mysqlObj.Spec.ReadinessGates,
+			Containers: []corev1.Container{
+				{
+					Name:           containerName,
+					Image:          mysqlImage(mysqlObj),
+					Ports:          containerPorts(mysqlObj),
+					Command:        readOnlyCommand(mysqlObj),
+					Args:           mysqlArgs(mysqlObj),
+					VolumeMounts:   volumeMounts(mysqlObj),
+					Env:            mysqlEnv(mysqlObj),
+					LivenessProbe:  newProbe(mysqlObj, mysqlObj.Spec.Probes.Liveness),
+					ReadinessProbe: newProbe(mysqlObj, mysqlObj.Spec.Probes.Readiness),
+					StartupProbe:   newProbe(mysqlObj, mysqlObj.Spec.Probes.Startup),
+					// The end of the log of a crash is kept in the pod
+					// status, see crashFailure.
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+					Lifecycle:                lifecycle(mysqlObj),
+				},
+			},
+		},
+	}
+	if mysqlObj.Spec.Metrics.Enabled {
+		podTemplate.Spec.Containers = append(podTemplate.Spec.Containers, exporterContainer(mysqlObj))
+	}
+	podTemplate.Spec.Containers = append(podTemplate.Spec.Containers, mysqlObj.Spec.Sidecars...)
+	if mysqlObj.Spec.BinlogStorage != nil {
+		// Only the data directory is chowned by the image entrypoint.
+		podTemplate.Spec.SecurityContext = &corev1.PodSecurityContext{
+			FSGroup: &mysqlGroupID,
+		}
+	}
+	if mysqlObj.Spec.Ephemeral {
+		podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, newEmptyDirVolume(volumeMountName))
+		if mysqlObj.Spec.BinlogStorage != nil {
+			podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, newEmptyDirVolume(binlogVolumeName))
+		}
+	}
+	if sources := configSources(mysqlObj); len(sources) > 0 {
+		podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, corev1.Volume{
+			Name: configVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: sources,
+				},
+			},
+		})
+	}
+	if mysqlObj.Spec.AuditLog.Enabled {
+		podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, newEmptyDirVolume(auditLogVolumeName))
+	}
+	if mysqlObj.Spec.StartupScript != "" {
+		podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, startupScriptVolume(mysqlObj))
+	}
+	for i, m := range mysqlObj.Spec.ExtraSecretMounts {
+		podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, corev1.Volume{
+			Name: extraSecretVolumeName(i),
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: m.SecretName,
+				},
+			},
+		})
+	}
+	return podTemplate
+}
+
+func newEmptyDirVolume(name string) corev1.Volume {
+	return corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+}
+
+// configSources returns the ConfigMaps projected in the config directory: the
+// one generated from the spec and the one named by the spec.
+func configSources(mysqlObj *mysqlalpha1.MySQL) []corev1.VolumeProjection {
+	var sources []corev1.VolumeProjection
+	if renderConfig(mysqlObj) != "" {
+		sources = append(sources, corev1.VolumeProjection{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: configMapName(mysqlObj),
+				},
+			},
+		})
+	}
+	if mysqlObj.Spec.ConfigMapName != "" {
+		sources = append(sources, corev1.VolumeProjection{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: mysqlObj.Spec.ConfigMapName,
+				},
+			},
+		})
+	}
+	return sources
+}
+
+// newProbe runs the command of the mode of the spec with its timings.
+func newProbe(mysqlObj *mysqlalpha1.MySQL, spec mysqlalpha1.MySQLProbeSpec) *corev1.Probe {
+	command := pingCommand(mysqlObj)
+	if spec.Mode == mysqlalpha1.ProbeModeQuery {
+		command = queryCommand(mysqlObj)
+	}
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"sh", "-c", command},
+			},
+		},
+		InitialDelaySeconds: spec.InitialDelaySeconds,
+		PeriodSeconds:       spec.PeriodSeconds,
+		TimeoutSeconds:      spec.TimeoutSeconds,
+		FailureThreshold:    spec.FailureThreshold,
+	}
+}
+
+// podAnnotations returns the annotations of the pod template. The config
+// checksum makes the StatefulSet roll when the config changes.
+func podAnnotations(mysqlObj *mysqlalpha1.MySQL, checksum string) map[string]string {
+	managed := map[string]string{}
+	if checksum != "" {
+		managed[configChecksumAnnotation] = checksum
+	}
+	if gangScheduled(mysqlObj) {
+		managed[podGroupAnnotation] = podGroupName(mysqlObj)
+	}
+	return mergeAnnotations(mysqlObj.Spec.PodAnnotations, managed)
+}
+
+// podLabels returns the labels of the pod template. The selector and instance
+// labels win over user labels with the same keys, so the pods stay selected.
+func podLabels(mysqlObj *mysqlalpha1.MySQL) map[string]string {
+	ret := map[string]string{}
+	for k, v := range mysqlObj.Spec.PodLabels {
+		ret[k] = v
+	}
+	for k, v := range LabelsForInstance(mysqlObj.Name) {
+		ret[k] = v
+	}
+	return ret
+}
+
+// refusedDowngrade returns the running version when the spec asks for a lower
+// one without allowing downgrades. Versions that do not parse, such as custom
+// image tags, are not compared.
+func refusedDowngrade(mysqlObj *mysqlalpha1.MySQL, sts *v1.StatefulSet) (string, bool) {
+	if mysqlObj.Spec.AllowDowngrade || sts == nil {
+		return "", false
+	}
+	var running string
+	for _, container := range sts.Spec.Template.Spec.Containers {
+		if container.Name == containerName {
+			running = imageTag(container.Image)
+		}
+	}
+	runningVersion, err := utilversion.ParseGeneric(running)
+	if err != nil {
+		return "", false
+	}
+	desiredVersion, err := utilversion.ParseGeneric(imageTag(mysqlImage(mysqlObj)))
+	if err != nil {
+		return "", false
+	}
+	return running, desiredVersion.LessThan(runningVersion)
+}
+
+// imageTag returns the tag of an image reference, or an empty string.
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
+
+// containerPorts returns the ports of the mysql container.
+func containerPorts(mysqlObj *mysqlalpha1.MySQL) []corev1.ContainerPort {
+	ports := []corev1.ContainerPort{
+		{
+			Name:          portName,
+			ContainerPort: mysqlPort(mysqlObj),
+		},
+	}
+	if mysqlObj.Spec.XProtocol.Enabled {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          xProtocolPortName,
+			ContainerPort: xProtocolPort,
+		})
+	}
+	return ports
+}
+
+// mysqlImage returns the image the MySQL runs: spec.image as is when set,
+// otherwise the repository of the flavor tagged with spec.version.
+func mysqlImage(mysqlObj *mysqlalpha1.MySQL) string {
+	if mysqlObj.Spec.Image != "" {
+		return mysqlObj.Spec.Image
+	}
+	return flavorOf(mysqlObj).repository + ":" + mysqlObj.Spec.Version
+}
+
+// pingCommand is the command of the probes in Ping mode, which only check
+// that the server answers.
+func pingCommand(mysqlObj *mysqlalpha1.MySQL) string {
+	f := flavorOf(mysqlObj)
+	return fmt.Sprintf("%s ping -h 127.0.0.1%s -uroot -p\"$%s\"", f.admin, portArg(mysqlObj), f.passwordEnv)
+}
+
+// queryCommand is the command of the probes in Query mode, which check that
+// the server serves queries.
+func queryCommand(mysqlObj *mysqlalpha1.MySQL) string {
+	f := flavorOf(mysqlObj)
+	return fmt.Sprintf("%s -h 127.0.0.1%s -uroot -p\"$%s\" -e 'SELECT 1'", f.client, portArg(mysqlObj), f.passwordEnv)
+}
+
+// shutdownGraceMargin is the part of the termination grace period left after
+// the shutdown timeout, for the preStop hook to start and return.
+var shutdownGraceMargin = 5 * time.Second
+
+// terminationGracePeriod returns the termination grace period of the pods,
+// longer than spec.shutdownTimeout so the shutdown run by the preStop hook is
+// not cut short.
+func terminationGracePeriod(mysqlObj *mysqlalpha1.MySQL) *int64 {
+	if mysqlObj.Spec.ShutdownTimeout == nil {
+		return &terminationGracePeriodSeconds
+	}
+	grace := int64(math.Ceil((mysqlObj.Spec.ShutdownTimeout.Duration + shutdownGraceMargin).Seconds()))
+	return &grace
+}
+
+// lifecycle returns the preStop hook shutting MySQL down within
+// spec.shutdownTimeout, or nil when unset and the server is only sent
+// SIGTERM.
+func lifecycle(mysqlObj *mysqlalpha1.MySQL) *corev1.Lifecycle {
+	if mysqlObj.Spec.ShutdownTimeout == nil {
+		return nil
+	}
+	f := flavorOf(mysqlObj)
+	timeout := int64(math.Ceil(mysqlObj.Spec.ShutdownTimeout.Seconds()))
+	command := fmt.Sprintf("%s -h 127.0.0.1%s -uroot -p\"$%s\" --shutdown-timeout=%d shutdown", f.admin, portArg(mysqlObj), f.passwordEnv, timeout)
+	return &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"sh", "-c", command},
+			},
+		},
+	}
+}
+
+// readOnlyCommand returns the command starting the server read-only, or nil
+// to run the entrypoint of the image. The server the entrypoint initializes
+// the data directory with reads the same config and flags, and must write:
+// an initialized server is started with the flags, a new one is switched to
+// read-only once the entrypoint started the final server, the only one
+// listening on TCP.
+func readOnlyCommand(mysqlObj *mysqlalpha1.MySQL) []string {
+	if !mysqlObj.Spec.ReadOnly {
+		return nil
+	}
+	f := flavorOf(mysqlObj)
+	flags, variable := "--read-only", "read_only"
+	if f.superReadOnly {
+		flags, variable = "--read-only --super-read-only", "super_read_only"
+	}
+	script := fmt.Sprintf(`if [ -d %s/mysql ]; then
+	set -- "$@" %s
+else
+	(until %s -h 127.0.0.1%s -uroot -p"$%s" -e 'SET GLOBAL %s = ON' 2>/dev/null; do sleep 1; done) &
+fi
+exec %s "$@"`, mysqlObj.Spec.DataDir, flags, f.client, portArg(mysqlObj), f.passwordEnv, variable, f.entrypoint)
+	// The arguments of the script follow its name, $0.
+	return []string{"sh", "-c", script, f.entrypoint}
+}
+
+// specReplicas returns the number of replicas requested by the spec.
+func specReplicas(mysqlObj *mysqlalpha1.MySQL) int32 {
+	return *mysqlObj.Spec.Replicas
+}
+
+// tooManyReplicas returns why spec.replicas is above Options.MaxReplicas, or
+// an empty string.
+func (c *Controller) tooManyReplicas(mysqlObj *mysqlalpha1.MySQL) string {
+	if c.options.MaxReplicas <= 0 || specReplicas(mysqlObj) <= c.options.MaxReplicas {
+		return ""
+	}
+	return fmt.Sprintf("spec.replicas %d is above the maximum of %d allowed by the operator", specReplicas(mysqlObj), c.options.MaxReplicas)
+}
+
+// desiredReplicas returns the number of replicas the StatefulSet should run.
+func desiredReplicas(mysqlObj *mysqlalpha1.MySQL) int32 {
+	if mysqlObj.Spec.Suspend || mysqlObj.Status.Restore != "" {
+		return 0
+	}
+	return specReplicas(mysqlObj)
+}
+
+func setSuspended(mysqlObj *mysqlalpha1.MySQL) {
+	cond := metav1.Condition{
+		Type:               mysqlalpha1.ConditionSuspended,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: mysqlObj.Generation,
+		Reason:             reasonAsExpected,
+	}
+	if mysqlObj.Spec.Suspend {
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = reasonSuspended
+		cond.Message = "Scaled down to zero replicas by spec.suspend"
+	}
+	meta.SetStatusCondition(&mysqlObj.Status.Conditions, cond)
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+func TestMySQLImage(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	for _, tc := range []struct {
+		name    string
+		flavor  mysqlalpha1.Flavor
+		version string
+		image   string
+		want    string
+	}{
+		{"version", mysqlalpha1.FlavorMySQL, "8.0", "", "arm64v8/mysql:8.0"},
+		{"flavor", mysqlalpha1.FlavorMariaDB, "10.11", "", "mariadb:10.11"},
+		{"image", mysqlalpha1.FlavorMySQL, "", "registry.example.com/db/mysql:8.0.36", "registry.example.com/db/mysql:8.0.36"},
+		{"image over version", mysqlalpha1.FlavorMySQL, "5.7", "registry.example.com/db/mysql:8.0.36", "registry.example.com/db/mysql:8.0.36"},
+		{"digest", mysqlalpha1.FlavorMySQL, "8.0", "mysql@" + digest, "mysql@" + digest},
+		{"tag and digest", mysqlalpha1.FlavorMariaDB, "", "mariadb:10.11@" + digest, "mariadb:10.11@" + digest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mysqlObj := &mysqlalpha1.MySQL{}
+			mysqlObj.Spec.Flavor = tc.flavor
+			mysqlObj.Spec.Version = tc.version
+			mysqlObj.Spec.Image = tc.image
+			if got := mysqlImage(mysqlObj); got != tc.want {
+				t.Errorf("mysqlImage() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadOnlyCommand(t *testing.T) {
+	mysqlObj := &mysqlalpha1.MySQL{}
+	mysqlObj.Spec.Flavor = mysqlalpha1.FlavorMySQL
+	mysqlObj.Spec.DataDir = "/var/lib/mysql"
+	if command := readOnlyCommand(mysqlObj); command != nil {
+		t.Errorf("readOnlyCommand() = %q, want the entrypoint of the image", command)
+	}
+
+	mysqlObj.Spec.ReadOnly = true
+	command := readOnlyCommand(mysqlObj)
+	if len(command) != 4 || command[0] != "sh" || command[3] != "docker-entrypoint.sh" {
+		t.Fatalf("readOnlyCommand() = %q", command)
+	}
+	script := command[2]
+	// Only an initialized server is started with the flags, the server
+	// initializing the data directory must write.
+	for _, want := range []string{
+		`if [ -d /var/lib/mysql/mysql ]; then`,
+		`set -- "$@" --read-only --super-read-only`,
+		`SET GLOBAL super_read_only = ON`,
+		`exec docker-entrypoint.sh "$@"`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script does not contain %q:\n%s", want, script)
+		}
+	}
+
+	mysqlObj.Spec.Flavor = mysqlalpha1.FlavorMariaDB
+	script = readOnlyCommand(mysqlObj)[2]
+	if strings.Contains(script, "super") || !strings.Contains(script, "SET GLOBAL read_only = ON") {
+		t.Errorf("mariadb script sets super_read_only:\n%s", script)
+	}
+}
+
+func TestNewVolumeClaimTemplateCopiesMetadata(t *testing.T) {
+	mysqlObj := &mysqlalpha1.MySQL{}
+	mysqlObj.Name = "db"
+	mysqlObj.Spec.PVCLabels = map[string]string{"backup": "daily", instanceLabelKey: "other"}
+	mysqlObj.Spec.PVCAnnotations = map[string]string{"example.com/owner": "team"}
+	storage := withDefaults(mysqlObj).Spec.Storage
+	claim := newVolumeClaimTemplate(mysqlObj, "data", storage)
+	if claim.Labels["backup"] != "daily" || claim.Labels[instanceLabelKey] != "db" || claim.Annotations["example.com/owner"] != "team" {
+		t.Fatalf("claim metadata = %v %v", claim.Labels, claim.Annotations)
+	}
+	// The template must not alias the maps of the MySQL from the cache.
+	claim.Labels["backup"] = "weekly"
+	claim.Annotations["example.com/owner"] = "other"
+	if mysqlObj.Spec.PVCLabels["backup"] != "daily" || mysqlObj.Spec.PVCAnnotations["example.com/owner"] != "team" {
+		t.Errorf("spec modified through the claim template")
+	}
+
+	if claim := newVolumeClaimTemplate(&mysqlalpha1.MySQL{}, "data", storage); claim.Annotations != nil {
+		t.Errorf("claim annotations = %v, want none", claim.Annotations)
+	}
+}
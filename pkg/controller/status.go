@@ -0,0 +1,369 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+	"github.com/cyhw/mysql-operator/pkg/validation"
+)
+
+var (
+	reasonAsExpected   = "AsExpected"
+	reasonSecretDrift  = "SecretDrift"
+	reasonSecretKey    = "SecretKeyMissing"
+	reasonTooMany      = "TooManyReplicas"
+	reasonSuspended    = "Suspended"
+	reasonPVCUnbound   = "PVCUnbound"
+	reasonScaledDown   = "ScaledToZero"
+	reasonInvalidImage = "InvalidImage"
+	reasonDowngrade    = "DowngradeRefused"
+	reasonCrash        = "CrashLoopBackOff"
+	crashMessageLines  = 3
+	messageRunning     = "Running"
+	messagePending     = "Pending"
+	imagePullFailures  = map[string]bool{
+		"ImagePullBackOff": true,
+		"ErrImagePull":     true,
+	}
+)
+
+// syncStatus fills the status of a MySQL from the state of its StatefulSet
+// and pods.
+func (c *Controller) syncStatus(mysqlObj *mysqlalpha1.MySQL) error {
+	mysqlObj.Status.Replicas = nil
+	mysqlObj.Status.MaxReplicationLagSeconds = nil
+	mysqlObj.Status.ReadOnly = false
+	mysqlObj.Status.AuditLog = false
+
+	// The StatefulSet is not created with an invalid image.
+	if err := validation.ValidateImage(mysqlImage(mysqlObj)); err != nil {
+		mysqlObj.Status.ConnectionEndpoint = ""
+		setDegraded(mysqlObj, reasonInvalidImage, err.Error())
+		return nil
+	}
+	// Nor without the password key.
+	message, err := c.secretKeyMissing(mysqlObj.Namespace)
+	if err != nil {
+		return err
+	}
+	if message != "" {
+		mysqlObj.Status.ConnectionEndpoint = ""
+		setDegraded(mysqlObj, reasonSecretKey, message)
+		return nil
+	}
+	// Nor updated above the replicas cap.
+	if message := c.tooManyReplicas(mysqlObj); message != "" {
+		setDegraded(mysqlObj, reasonTooMany, message)
+		return nil
+	}
+
+	sts, err := c.statefulSetLister.StatefulSets(mysqlObj.Namespace).Get(statefulSetName(mysqlObj))
+	if apierrors.IsNotFound(err) {
+		mysqlObj.Status.ConnectionEndpoint = ""
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if running, refused := refusedDowngrade(mysqlObj, sts); refused {
+		setDegraded(mysqlObj, reasonDowngrade, fmt.Sprintf("Version %s is lower than the running version %s, set spec.allowDowngrade to downgrade", imageTag(mysqlImage(mysqlObj)), running))
+		return nil
+	}
+	setProgressing(mysqlObj, sts)
+
+	if desiredReplicas(mysqlObj) == 0 {
+		// Nothing is expected to run, which is not a failure.
+		mysqlObj.Status.ConnectionEndpoint = ""
+		meta.SetStatusCondition(&mysqlObj.Status.Conditions, metav1.Condition{
+			Type:               mysqlalpha1.ConditionDegraded,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: mysqlObj.Generation,
+			Reason:             reasonScaledDown,
+			Message:            "Scaled down to zero replicas",
+		})
+		return nil
+	}
+
+	if sts.Status.ReadyReplicas > 0 {
+		mysqlObj.Status.ConnectionEndpoint = connectionEndpoint(mysqlObj)
+	} else {
+		mysqlObj.Status.ConnectionEndpoint = ""
+	}
+	ready := sts.Status.ReadyReplicas >= desiredReplicas(mysqlObj)
+	if ready {
+		mysqlObj.Status.Message = messageRunning
+	} else {
+		mysqlObj.Status.Message = messagePending
+	}
+	c.pollReadiness(mysqlObj, ready)
+	// The config of the spec applies once its pods are all ready. The server
+	// does not start without the audit log plugin.
+	rolledOut := ready && !meta.IsStatusConditionTrue(mysqlObj.Status.Conditions, mysqlalpha1.ConditionProgressing)
+	mysqlObj.Status.ReadOnly = mysqlObj.Spec.ReadOnly && rolledOut
+	mysqlObj.Status.AuditLog = mysqlObj.Spec.AuditLog.Enabled && rolledOut
+
+	pods, err := c.listPods(sts)
+	if err != nil {
+		return err
+	}
+	if image := runningImage(pods); image != "" {
+		mysqlObj.Status.RunningImage = image
+	}
+	c.checkClaimTopology(mysqlObj)
+	reason, message := imagePullFailure(pods)
+	if reason == "" {
+		reason, message = crashFailure(pods)
+	}
+	if reason == "" {
+		reason, message, err = c.unboundClaim(mysqlObj, pods)
+		if err != nil {
+			return err
+		}
+	}
+	if reason == "" {
+		reason, message, err = c.secretDrift(mysqlObj.Namespace)
+		if err != nil {
+			return err
+		}
+	}
+	c.syncReplication(mysqlObj, pods)
+	if reason == "" {
+		reason, message = replicationLagged(mysqlObj, c.options.MaxReplicationLag)
+	}
+	setDegraded(mysqlObj, reason, message)
+	return nil
+}
+
+// pollReadiness requeues a MySQL whose pods are not all ready, backing off
+// exponentially, and resets the backoff once they are.
+func (c *Controller) pollReadiness(mysqlObj *mysqlalpha1.MySQL, ready bool) {
+	if c.options.ReadinessBackoffInitial <= 0 {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(mysqlObj)
+	if err != nil {
+		klog.ErrorS(err, "Failed to get key", "obj", mysqlObj)
+		return
+	}
+	if ready {
+		c.readinessBackoff.Forget(key)
+		return
+	}
+	c.queue.AddAfter(key, c.readinessBackoff.When(key))
+}
+
+// listPods returns the pods controlled by the StatefulSet.
+func (c *Controller) listPods(sts *v1.StatefulSet) ([]*corev1.Pod, error) {
+	pods, err := c.podLister.Pods(sts.Namespace).List(labels.SelectorFromSet(sts.Spec.Selector.MatchLabels))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*corev1.Pod
+	for _, pod := range pods {
+		if ownerRef := metav1.GetControllerOf(pod); ownerRef != nil && ownerRef.UID == sts.UID {
+			ret = append(ret, pod)
+		}
+	}
+	return ret, nil
+}
+
+// imagePullFailure returns the reason and message of the first container that
+// fails to pull its image.
+func imagePullFailure(pods []*corev1.Pod) (string, string) {
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting == nil || !imagePullFailures[status.State.Waiting.Reason] {
+				continue
+			}
+			return status.State.Waiting.Reason, fmt.Sprintf("Pod %s container %s: %s", pod.Name, status.Name, status.State.Waiting.Message)
+		}
+	}
+	return "", ""
+}
+
+// runningImage returns the image ID the mysql containers of the pods run, or
+// an empty string if none runs yet or they run different images, as during a
+// rollout. The image ID is the reference of the image with its digest, such as
+// docker.io/library/mysql@sha256:..., prefixed by some runtimes with a scheme.
+func runningImage(pods []*corev1.Pod) string {
+	var ret string
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != containerName || status.ImageID == "" {
+				continue
+			}
+			image := status.ImageID
+			if i := strings.Index(image, "://"); i >= 0 {
+				image = image[i+len("://"):]
+			}
+			if ret != "" && ret != image {
+				return ""
+			}
+			ret = image
+		}
+	}
+	return ret
+}
+
+// crashFailure returns the reason and message of the first mysql container
+// restarted after a crash, with the reason it was terminated for, such as
+// OOMKilled, and the end of the log it left as termination message.
+func crashFailure(pods []*corev1.Pod) (string, string) {
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != containerName || status.State.Waiting == nil || status.State.Waiting.Reason != reasonCrash {
+				continue
+			}
+			terminated := status.LastTerminationState.Terminated
+			if terminated == nil {
+				return reasonCrash, fmt.Sprintf("Pod %s container %s: %s", pod.Name, status.Name, status.State.Waiting.Message)
+			}
+			return reasonCrash, fmt.Sprintf("Pod %s container %s exited with code %d (%s): %s",
+				pod.Name, status.Name, terminated.ExitCode, terminated.Reason, tailLines(terminated.Message, crashMessageLines))
+		}
+	}
+	return "", ""
+}
+
+// tailLines returns the last n non-empty lines of a log.
+func tailLines(log string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(log, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// unboundClaim reports a PVC of the MySQL that was lost, or that is pending
+// while a pod cannot be scheduled. Claims of storage classes binding on first
+// consumer are pending until their pod is scheduled, so a pending claim alone
+// is not a failure.
+func (c *Controller) unboundClaim(mysqlObj *mysqlalpha1.MySQL, pods []*corev1.Pod) (string, string, error) {
+	claims, err := c.claimLister.PersistentVolumeClaims(mysqlObj.Namespace).List(SelectorForInstance(mysqlObj.Name))
+	if err != nil {
+		return "", "", err
+	}
+
+	var pending []string
+	for _, claim := range claims {
+		if !isClaimOfMySQL(mysqlObj, claim.Name) {
+			continue
+		}
+		switch claim.Status.Phase {
+		case corev1.ClaimLost:
+			return reasonPVCUnbound, fmt.Sprintf("PersistentVolumeClaim %s lost its volume", claim.Name), nil
+		case corev1.ClaimPending:
+			pending = append(pending, claim.Name)
+		}
+	}
+	if len(pending) == 0 {
+		return "", "", nil
+	}
+
+	sort.Strings(pending)
+	for _, pod := range pods {
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse {
+				return reasonPVCUnbound, fmt.Sprintf("PersistentVolumeClaim %s is pending, pod %s: %s",
+					strings.Join(pending, ", "), pod.Name, cond.Message), nil
+			}
+		}
+	}
+	return "", "", nil
+}
+
+// secretDrift reports a password Secret edited after MySQL was initialized
+// from it. MySQL only reads the root password on first start, so the Secret no
+// longer matches the live password. The drift is only reported: the Secret is
+// not reverted, as the live password may have been changed on purpose.
+func (c *Controller) secretDrift(namespace string) (string, string, error) {
+	secret, err := c.secretLister.Secrets(namespace).Get(secretName)
+	if apierrors.IsNotFound(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	hash, ok := secret.Annotations[passwordHashAnnotation]
+	if !ok || hash == hashObject(string(secret.Data[envName])) {
+		return "", "", nil
+	}
+	return reasonSecretDrift, fmt.Sprintf("Secret %s key %s changed after initialization and no longer matches the database password", secretName, envName), nil
+}
+
+// secretKeyMissing reports a password Secret without the key the containers
+// read the root password from, with which MySQL fails to start. A missing
+// Secret is created by syncSecret.
+func (c *Controller) secretKeyMissing(namespace string) (string, error) {
+	secret, err := c.secretLister.Secrets(namespace).Get(secretName)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, ok := secret.Data[envName]; ok {
+		return "", nil
+	}
+	return fmt.Sprintf("Secret %s has no key %s holding the root password", secretName, envName), nil
+}
+
+// setDegraded sets the Degraded condition, which is False when reason is empty.
+func setDegraded(mysqlObj *mysqlalpha1.MySQL, reason, message string) {
+	cond := metav1.Condition{
+		Type:               mysqlalpha1.ConditionDegraded,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: mysqlObj.Generation,
+		Reason:             reason,
+		Message:            message,
+	}
+	if reason == "" {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = reasonAsExpected
+	}
+	meta.SetStatusCondition(&mysqlObj.Status.Conditions, cond)
+}
+
+func connectionEndpoint(mysqlObj *mysqlalpha1.MySQL) string {
+	return fmt.Sprintf("%s:%d", clientHost(mysqlObj), mysqlPort(mysqlObj))
+}
+
+// clientHost returns the host clients connect to, which is the proxy when
+// enabled.
+func clientHost(mysqlObj *mysqlalpha1.MySQL) string {
+	if mysqlObj.Spec.Proxy.Enabled {
+		return fmt.Sprintf("%s.%s.svc.%s", proxyName(mysqlObj), mysqlObj.Namespace, clusterDomain)
+	}
+	return clientServiceHost(mysqlObj)
+}
+
+// clientServiceHost returns the host of the client Service, which routes to
+// the ready pods of the serving StatefulSet.
+func clientServiceHost(mysqlObj *mysqlalpha1.MySQL) string {
+	return fmt.Sprintf("%s.%s.svc.%s", clientServiceName(mysqlObj), mysqlObj.Namespace, clusterDomain)
+}
+
+// serviceHost returns the host of the headless Service, under which every
+// pod has a record.
+func serviceHost(mysqlObj *mysqlalpha1.MySQL) string {
+	return fmt.Sprintf("%s.%s.svc.%s", headlessServiceName(mysqlObj), mysqlObj.Namespace, clusterDomain)
+}
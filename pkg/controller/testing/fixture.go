@@ -0,0 +1,209 @@
+// Package testing runs the MySQL controller against fake clientsets, so its
+// reconcile can be exercised without an API server.
+package testing
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubeinformer "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+	crfake "github.com/cyhw/mysql-operator/pkg/clients/clientset/versioned/fake"
+	crinformer "github.com/cyhw/mysql-operator/pkg/clients/informers/externalversions"
+	"github.com/cyhw/mysql-operator/pkg/controller"
+)
+
+// Fixture is a Controller wired to fake clientsets, seeded with objects.
+type Fixture struct {
+	K8sClient     *k8sfake.Clientset
+	CRClient      *crfake.Clientset
+	DynamicClient *dynamicfake.FakeDynamicClient
+	Controller    *controller.Controller
+
+	caches []fixtureCache
+}
+
+// fixtureCache is the store of an informer and the list filling it.
+type fixtureCache struct {
+	store   cache.Store
+	list    func(metav1.ListOptions) (runtime.Object, error)
+	managed bool
+}
+
+// NewFixture builds a Controller the way the operator does, on fake
+// clientsets holding the given Kubernetes objects and MySQLs. The informers
+// are not started: Reconcile fills their caches from the clientsets, so the
+// reconciles see the writes of the previous ones without waiting for events.
+func NewFixture(kubeObjects, crObjects []runtime.Object, options controller.Options) *Fixture {
+	f := &Fixture{
+		K8sClient:     k8sfake.NewSimpleClientset(kubeObjects...),
+		CRClient:      crfake.NewSimpleClientset(crObjects...),
+		DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+	}
+	crInformerFactory := crinformer.NewSharedInformerFactory(f.CRClient, 0)
+	kubeInformerFactory := kubeinformer.NewSharedInformerFactory(f.K8sClient, 0)
+	managedInformerFactory := kubeinformer.NewSharedInformerFactoryWithOptions(f.K8sClient, 0,
+		kubeinformer.WithTweakListOptions(controller.ManagedListOptions))
+
+	ctx := context.Background()
+	mysqls := crInformerFactory.Volc().V1alpha1().MySQLs()
+	f.addCache(mysqls.Informer(), false, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.CRClient.VolcV1alpha1().MySQLs("").List(ctx, o)
+	})
+	restores := crInformerFactory.Volc().V1alpha1().MySQLRestores()
+	f.addCache(restores.Informer(), false, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.CRClient.VolcV1alpha1().MySQLRestores("").List(ctx, o)
+	})
+	statefulSets := kubeInformerFactory.Apps().V1().StatefulSets()
+	f.addCache(statefulSets.Informer(), false, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.AppsV1().StatefulSets("").List(ctx, o)
+	})
+	cronJobs := kubeInformerFactory.Batch().V1().CronJobs()
+	f.addCache(cronJobs.Informer(), false, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.BatchV1().CronJobs("").List(ctx, o)
+	})
+	pods := managedInformerFactory.Core().V1().Pods()
+	f.addCache(pods.Informer(), true, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.CoreV1().Pods("").List(ctx, o)
+	})
+	secrets := managedInformerFactory.Core().V1().Secrets()
+	f.addCache(secrets.Informer(), true, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.CoreV1().Secrets("").List(ctx, o)
+	})
+	services := managedInformerFactory.Core().V1().Services()
+	f.addCache(services.Informer(), true, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.CoreV1().Services("").List(ctx, o)
+	})
+	claims := managedInformerFactory.Core().V1().PersistentVolumeClaims()
+	f.addCache(claims.Informer(), true, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.CoreV1().PersistentVolumeClaims("").List(ctx, o)
+	})
+	networkPolicies := kubeInformerFactory.Networking().V1().NetworkPolicies()
+	f.addCache(networkPolicies.Informer(), false, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.NetworkingV1().NetworkPolicies("").List(ctx, o)
+	})
+	configMaps := kubeInformerFactory.Core().V1().ConfigMaps()
+	f.addCache(configMaps.Informer(), false, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.CoreV1().ConfigMaps("").List(ctx, o)
+	})
+
+	deployments := managedInformerFactory.Apps().V1().Deployments()
+	f.addCache(deployments.Informer(), true, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.AppsV1().Deployments("").List(ctx, o)
+	})
+	jobs := managedInformerFactory.Batch().V1().Jobs()
+	f.addCache(jobs.Informer(), true, func(o metav1.ListOptions) (runtime.Object, error) {
+		return f.K8sClient.BatchV1().Jobs("").List(ctx, o)
+	})
+
+	f.Controller = controller.NewController(f.K8sClient, f.CRClient, f.DynamicClient,
+		mysqls, restores, statefulSets, cronJobs, pods, secrets, services, claims, networkPolicies, configMaps, deployments,
+		jobs, options)
+	return f
+}
+
+func (f *Fixture) addCache(informer cache.SharedIndexInformer, managed bool, list func(metav1.ListOptions) (runtime.Object, error)) {
+	f.caches = append(f.caches, fixtureCache{store: informer.GetStore(), list: list, managed: managed})
+}
+
+// SyncCaches replaces the content of the informer caches with the objects
+// held by the clientsets, as a relist of the informers would.
+func (f *Fixture) SyncCaches() error {
+	for _, c := range f.caches {
+		options := metav1.ListOptions{}
+		if c.managed {
+			controller.ManagedListOptions(&options)
+		}
+		list, err := c.list(options)
+		if err != nil {
+			return err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		objs := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			objs = append(objs, item)
+		}
+		if err := c.store.Replace(objs, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reconcile reconciles the MySQL once, after syncing the caches so the
+// objects written by a previous reconcile are seen.
+func (f *Fixture) Reconcile(mysqlObj *mysqlalpha1.MySQL) error {
+	key, err := cache.MetaNamespaceKeyFunc(mysqlObj)
+	if err != nil {
+		return err
+	}
+	if err := f.SyncCaches(); err != nil {
+		return err
+	}
+	return f.Controller.Reconcile(context.Background(), key)
+}
+
+// FailOn makes the fake Kubernetes clientset fail the verb on the resource,
+// such as "create" and "secrets", with the error.
+func (f *Fixture) FailOn(verb, resource string, err error) {
+	f.K8sClient.PrependReactor(verb, resource, func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, err
+	})
+}
+
+// Created returns the objects of the resource created through the fake
+// Kubernetes clientset.
+func (f *Fixture) Created(resource string) []runtime.Object {
+	var ret []runtime.Object
+	for _, action := range f.K8sClient.Actions() {
+		create, ok := action.(clienttesting.CreateAction)
+		if ok && action.GetVerb() == "create" && action.GetResource().Resource == resource {
+			ret = append(ret, create.GetObject())
+		}
+	}
+	return ret
+}
+
+// Deleted returns the names of the objects of the resource deleted through
+// the fake Kubernetes clientset.
+func (f *Fixture) Deleted(resource string) []string {
+	var ret []string
+	for _, action := range f.K8sClient.Actions() {
+		del, ok := action.(clienttesting.DeleteAction)
+		if ok && action.GetResource().Resource == resource {
+			ret = append(ret, del.GetName())
+		}
+	}
+	return ret
+}
+
+// NewMySQL returns a MySQL with the fields the API server would set on
+// creation.
+func NewMySQL(namespace, name, version string) *mysqlalpha1.MySQL {
+	return &mysqlalpha1.MySQL{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: mysqlalpha1.SchemeGroupVersion.String(),
+			Kind:       "MySQL",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  namespace,
+			Name:       name,
+			UID:        types.UID("uid-" + name),
+			Generation: 1,
+		},
+		Spec: mysqlalpha1.MySQLSpec{
+			Version: version,
+		},
+	}
+}
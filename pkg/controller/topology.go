@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var reasonClaimTopology = "ImmediateVolumeBinding"
+
+// checkClaimTopology warns once about a MySQL spread across zones whose data
+// claims use a storage class binding volumes immediately. Such volumes are
+// provisioned before the pod is scheduled, in a zone chosen regardless of
+// the spread, and pin the pod to that zone from then on. With
+// WaitForFirstConsumer the volume follows the zone the pod is scheduled to.
+// The check is advisory, its failures are only logged.
+func (c *Controller) checkClaimTopology(mysqlObj *mysqlalpha1.MySQL) {
+	if !spreadsAcrossZones(mysqlObj) {
+		return
+	}
+	key := mysqlObj.Namespace + "/" + mysqlObj.Name
+	if _, warned := c.topologyWarned.Load(key); warned {
+		return
+	}
+	claims, err := c.claimLister.PersistentVolumeClaims(mysqlObj.Namespace).List(SelectorForInstance(mysqlObj.Name))
+	if err != nil {
+		klog.ErrorS(err, "Failed to list PVCs", "namespace", mysqlObj.Namespace)
+		return
+	}
+	for _, claim := range claims {
+		if !isClaimOfMySQL(mysqlObj, claim.Name) || claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName == "" {
+			continue
+		}
+		mode, err := c.volumeBindingMode(*claim.Spec.StorageClassName)
+		if err != nil {
+			klog.ErrorS(err, "Failed to get storage class", "name", *claim.Spec.StorageClassName)
+			return
+		}
+		if mode == storagev1.VolumeBindingWaitForFirstConsumer {
+			continue
+		}
+		c.topologyWarned.Store(key, true)
+		klog.InfoS("Storage class binds volumes immediately, pods may not spread across zones.",
+			"namespace", mysqlObj.Namespace, "name", mysqlObj.Name, "storageClass", *claim.Spec.StorageClassName)
+		c.recorder.Eventf(mysqlObj, corev1.EventTypeWarning, reasonClaimTopology,
+			"Storage class %s binds volumes before the pods are scheduled, use one with volumeBindingMode WaitForFirstConsumer to spread the pods across zones", *claim.Spec.StorageClassName)
+		return
+	}
+}
+
+// volumeBindingMode returns the binding mode of the storage class. It is
+// immutable, so it is only read once.
+func (c *Controller) volumeBindingMode(name string) (storagev1.VolumeBindingMode, error) {
+	if mode, ok := c.bindingModes.Load(name); ok {
+		return mode.(storagev1.VolumeBindingMode), nil
+	}
+	class, err := c.k8sClient.StorageV1().StorageClasses().Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	mode := storagev1.VolumeBindingImmediate
+	if class.VolumeBindingMode != nil {
+		mode = *class.VolumeBindingMode
+	}
+	c.bindingModes.Store(name, mode)
+	return mode, nil
+}
+
+// spreadsAcrossZones reports whether the pods of the MySQL are spread across
+// zones.
+func spreadsAcrossZones(mysqlObj *mysqlalpha1.MySQL) bool {
+	for _, constraint := range topologySpreadConstraints(mysqlObj, specReplicas(mysqlObj)) {
+		if constraint.TopologyKey == zoneTopologyKey {
+			return true
+		}
+	}
+	return false
+}
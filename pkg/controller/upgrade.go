@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+var (
+	upgradeTargetAnnotation = "volc.bytedance.com/upgrade-target"
+	reasonUpgradeBackup     = "UpgradeBackup"
+	reasonBlueGreen         = "BlueGreenUpgrade"
+	reasonRollingUpdate     = "RollingUpdate"
+)
+
+// syncUpgradeBackup dumps the databases before the StatefulSet is moved to a
+// new image, and reports whether the upgrade may proceed. The Job is kept
+// until the next upgrade replaces it.
+func (c *Controller) syncUpgradeBackup(mysqlObj *mysqlalpha1.MySQL, sts *v1.StatefulSet) (bool, error) {
+	if !upgradePending(mysqlObj, sts) {
+		return true, nil
+	}
+	if mysqlObj.Spec.Backup.ClaimName == "" {
+		return false, fmt.Errorf("spec.upgrade.backupFirst requires spec.backup.claimName")
+	}
+
+	name := upgradeBackupJobName(mysqlObj)
+	target := mysqlImage(mysqlObj)
+	job, err := c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err == nil && job.Annotations[upgradeTargetAnnotation] != target {
+		propagation := metav1.DeletePropagationBackground
+		err = c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Delete(context.Background(), name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+		klog.InfoS("Delete stale upgrade backup job.", "namespace", mysqlObj.Namespace, "name", name)
+		c.enqueueAfter(mysqlObj, backupPollInterval)
+		return false, nil
+	}
+	if apierrors.IsNotFound(err) {
+		_, err = c.k8sClient.BatchV1().Jobs(mysqlObj.Namespace).Create(context.Background(), newUpgradeBackupJob(mysqlObj, sts), metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		klog.InfoS("Create upgrade backup job.", "namespace", mysqlObj.Namespace, "name", name, "target", target)
+		c.enqueueAfter(mysqlObj, backupPollInterval)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			mysqlObj.Status.UpgradeBackup = upgradeBackupFile(mysqlObj)
+			return true, nil
+		case batchv1.JobFailed:
+			return false, fmt.Errorf("upgrade backup job %s failed: %s", name, cond.Message)
+		}
+	}
+	klog.InfoS("Wait for upgrade backup.", "namespace", mysqlObj.Namespace, "name", name)
+	c.enqueueAfter(mysqlObj, backupPollInterval)
+	return false, nil
+}
+
+// upgradePending reports whether the StatefulSet is to be moved to a new image
+// once backed up.
+func upgradePending(mysqlObj *mysqlalpha1.MySQL, sts *v1.StatefulSet) bool {
+	return mysqlObj.Spec.Upgrade.BackupFirst && sts != nil && containerImage(sts) != mysqlImage(mysqlObj)
+}
+
+// setProgressing sets the Progressing condition from the state of the
+// StatefulSet.
+func setProgressing(mysqlObj *mysqlalpha1.MySQL, sts *v1.StatefulSet) {
+	cond := metav1.Condition{
+		Type:               mysqlalpha1.ConditionProgressing,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: mysqlObj.Generation,
+	}
+	switch {
+	case candidateStatefulSetName(mysqlObj) != "":
+		cond.Reason = reasonBlueGreen
+		cond.Message = fmt.Sprintf("StatefulSet %s is taking over from %s", candidateStatefulSetName(mysqlObj), sts.Name)
+	case upgradePending(mysqlObj, sts):
+		cond.Reason = reasonUpgradeBackup
+		cond.Message = fmt.Sprintf("Backing up before the upgrade to %s", mysqlImage(mysqlObj))
+	case sts.Status.ObservedGeneration < sts.Generation || sts.Status.UpdateRevision != sts.Status.CurrentRevision ||
+		sts.Status.UpdatedReplicas < desiredReplicas(mysqlObj):
+		cond.Reason = reasonRollingUpdate
+		cond.Message = fmt.Sprintf("%d of %d replicas updated", sts.Status.UpdatedReplicas, desiredReplicas(mysqlObj))
+	default:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = reasonAsExpected
+	}
+	meta.SetStatusCondition(&mysqlObj.Status.Conditions, cond)
+}
+
+func upgradeBackupJobName(mysqlObj *mysqlalpha1.MySQL) string {
+	return mysqlObj.Name + "-upgrade-backup"
+}
+
+// upgradeBackupFile returns the name of the dump taken before the upgrade to
+// the image of the spec.
+func upgradeBackupFile(mysqlObj *mysqlalpha1.MySQL) string {
+	return fmt.Sprintf("%s-upgrade-%s.sql", mysqlObj.Name, hashObject(mysqlImage(mysqlObj)))
+}
+
+func newUpgradeBackupJob(mysqlObj *mysqlalpha1.MySQL, sts *v1.StatefulSet) *batchv1.Job {
+	dump := fmt.Sprintf("%s -h %s%s -uroot -p\"$%s\" --all-databases --single-transaction > %s/%s",
+		flavorOf(mysqlObj).dump, clientServiceHost(mysqlObj), portArg(mysqlObj), flavorOf(mysqlObj).passwordEnv, backupMountPath, upgradeBackupFile(mysqlObj))
+
+	// The dump is taken with the client of the running version.
+	spec := newBackupJobSpec(mysqlObj, dump)
+	spec.Template.Spec.Containers[0].Image = containerImage(sts)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      upgradeBackupJobName(mysqlObj),
+			Namespace: mysqlObj.Namespace,
+			Annotations: map[string]string{
+				upgradeTargetAnnotation: mysqlImage(mysqlObj),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*newOwnerRef(mysqlObj),
+			},
+		},
+		Spec: spec,
+	}
+}
@@ -0,0 +1,130 @@
+// Package conversion converts MySQL objects between the served versions of the
+// API, for the conversion webhook of the CRD. Versions convert through a hub,
+// so each new version only needs a converter to and from the hub.
+package conversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// Path is the path the webhook is served on.
+const Path = "/convert"
+
+// Hub is the version every object is converted through.
+var Hub = mysqlalpha1.SchemeGroupVersion
+
+// Spoke converts the objects of a version other than the hub, in place.
+type Spoke interface {
+	ToHub(obj *unstructured.Unstructured) error
+	FromHub(obj *unstructured.Unstructured) error
+}
+
+// Spokes are the other served versions, by version. The hub is the only
+// version for now.
+var Spokes = map[string]Spoke{}
+
+// Convert converts an object of the group to the desired version.
+func Convert(obj *unstructured.Unstructured, desired schema.GroupVersion) error {
+	from, err := schema.ParseGroupVersion(obj.GetAPIVersion())
+	if err != nil {
+		return err
+	}
+	if from.Group != Hub.Group || desired.Group != Hub.Group {
+		return fmt.Errorf("cannot convert %s to %s", from, desired)
+	}
+	if from == desired {
+		return nil
+	}
+
+	if from != Hub {
+		spoke, ok := Spokes[from.Version]
+		if !ok {
+			return fmt.Errorf("unknown version %s", from)
+		}
+		if err := spoke.ToHub(obj); err != nil {
+			return err
+		}
+		obj.SetAPIVersion(Hub.String())
+	}
+	if desired != Hub {
+		spoke, ok := Spokes[desired.Version]
+		if !ok {
+			return fmt.Errorf("unknown version %s", desired)
+		}
+		if err := spoke.FromHub(obj); err != nil {
+			return err
+		}
+		obj.SetAPIVersion(desired.String())
+	}
+	return nil
+}
+
+// Handler serves the ConversionReviews of the API server.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in apiextensionsv1.ConversionReview
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Request == nil {
+			http.Error(w, "invalid ConversionReview", http.StatusBadRequest)
+			return
+		}
+
+		out := apiextensionsv1.ConversionReview{
+			TypeMeta: in.TypeMeta,
+			Response: convertAll(in.Request),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			klog.ErrorS(err, "Failed to write ConversionReview")
+		}
+	})
+}
+
+// convertAll converts the objects of the request, failing them all when one
+// fails as the API server requires.
+func convertAll(request *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	response := &apiextensionsv1.ConversionResponse{
+		UID: request.UID,
+		Result: metav1.Status{
+			Status: metav1.StatusSuccess,
+		},
+	}
+	fail := func(err error) *apiextensionsv1.ConversionResponse {
+		response.ConvertedObjects = nil
+		response.Result = metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: err.Error(),
+		}
+		return response
+	}
+
+	desired, err := schema.ParseGroupVersion(request.DesiredAPIVersion)
+	if err != nil {
+		return fail(err)
+	}
+	for _, raw := range request.Objects {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw.Raw); err != nil {
+			return fail(err)
+		}
+		if err := Convert(obj, desired); err != nil {
+			return fail(err)
+		}
+		data, err := obj.MarshalJSON()
+		if err != nil {
+			return fail(err)
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: data})
+	}
+	return response
+}
@@ -0,0 +1,134 @@
+package conversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// renameSpoke is a version calling spec.version spec.serverVersion.
+type renameSpoke struct{}
+
+func (renameSpoke) ToHub(obj *unstructured.Unstructured) error {
+	return move(obj, "serverVersion", "version")
+}
+
+func (renameSpoke) FromHub(obj *unstructured.Unstructured) error {
+	return move(obj, "version", "serverVersion")
+}
+
+func move(obj *unstructured.Unstructured, from, to string) error {
+	value, found, err := unstructured.NestedString(obj.Object, "spec", from)
+	if err != nil || !found {
+		return err
+	}
+	unstructured.RemoveNestedField(obj.Object, "spec", from)
+	return unstructured.SetNestedField(obj.Object, value, "spec", to)
+}
+
+func newObject() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": Hub.String(),
+		"kind":       "MySQL",
+		"metadata": map[string]interface{}{
+			"namespace": "ns",
+			"name":      "db",
+		},
+		"spec": map[string]interface{}{
+			"version":  "8.0",
+			"replicas": int64(3),
+		},
+	}}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	spoke := schema.GroupVersion{Group: Hub.Group, Version: "v1beta1"}
+	Spokes[spoke.Version] = renameSpoke{}
+	defer delete(Spokes, spoke.Version)
+
+	obj := newObject()
+	if err := Convert(obj, spoke); err != nil {
+		t.Fatalf("Convert() to %s = %v", spoke, err)
+	}
+	if obj.GetAPIVersion() != spoke.String() {
+		t.Errorf("apiVersion = %s, want %s", obj.GetAPIVersion(), spoke)
+	}
+	if version, _, _ := unstructured.NestedString(obj.Object, "spec", "serverVersion"); version != "8.0" {
+		t.Errorf("spoke spec = %v", obj.Object["spec"])
+	}
+	if err := Convert(obj, Hub); err != nil {
+		t.Fatalf("Convert() to %s = %v", Hub, err)
+	}
+	if want := newObject(); !equality.Semantic.DeepEqual(obj.Object, want.Object) {
+		t.Errorf("round trip = %v, want %v", obj.Object, want.Object)
+	}
+
+	if err := Convert(newObject(), schema.GroupVersion{Group: Hub.Group, Version: "v2"}); err == nil {
+		t.Errorf("Convert() to an unknown version succeeded")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	raw, err := newObject().MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name    string
+		desired string
+		status  string
+	}{
+		{"identity", Hub.String(), metav1.StatusSuccess},
+		{"unknown version", Hub.Group + "/v2", metav1.StatusFailure},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in := apiextensionsv1.ConversionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+				Request: &apiextensionsv1.ConversionRequest{
+					UID:               "uid",
+					DesiredAPIVersion: tc.desired,
+					Objects:           []runtime.RawExtension{{Raw: raw}},
+				},
+			}
+			body, err := json.Marshal(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := httptest.NewRecorder()
+			Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, Path, bytes.NewReader(body)))
+
+			var out apiextensionsv1.ConversionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &out); err != nil {
+				t.Fatalf("response %q: %v", recorder.Body.String(), err)
+			}
+			if out.Kind != "ConversionReview" || out.Response == nil || out.Response.UID != "uid" {
+				t.Fatalf("response = %+v", out)
+			}
+			if out.Response.Result.Status != tc.status {
+				t.Errorf("status = %s, want %s: %s", out.Response.Result.Status, tc.status, out.Response.Result.Message)
+			}
+			if tc.status != metav1.StatusSuccess {
+				return
+			}
+			if len(out.Response.ConvertedObjects) != 1 {
+				t.Fatalf("converted %d objects, want 1", len(out.Response.ConvertedObjects))
+			}
+			converted := &unstructured.Unstructured{}
+			if err := converted.UnmarshalJSON(out.Response.ConvertedObjects[0].Raw); err != nil {
+				t.Fatal(err)
+			}
+			if want := newObject(); !equality.Semantic.DeepEqual(converted.Object, want.Object) {
+				t.Errorf("converted = %v, want %v", converted.Object, want.Object)
+			}
+		})
+	}
+}
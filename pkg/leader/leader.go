@@ -0,0 +1,117 @@
+// Package leader runs the operator under a Lease based leader election and
+// reports the state of the election.
+package leader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+var (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// Status is the state of the election as seen by this replica.
+type Status struct {
+	Identity string `json:"identity"`
+	Leader   bool   `json:"leader"`
+	Holder   string `json:"holder,omitempty"`
+	// Electing is true once this replica takes part in the election.
+	Electing bool `json:"electing"`
+}
+
+// Elector tracks the state of the election of this replica.
+type Elector struct {
+	lock   sync.Mutex
+	status Status
+}
+
+// NewElector creates an Elector for the given identity.
+func NewElector(identity string) *Elector {
+	return &Elector{status: Status{Identity: identity}}
+}
+
+// Status returns the state of the election.
+func (e *Elector) Status() Status {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.status
+}
+
+// Ready reports an error until this replica takes part in the election.
+// Standbys are ready, so they can take over.
+func (e *Elector) Ready(*http.Request) error {
+	if !e.Status().Electing {
+		return errors.New("not electing")
+	}
+	return nil
+}
+
+// RunAlone runs the operator without election, as the only leader.
+func (e *Elector) RunAlone(ctx context.Context, run func(ctx context.Context)) {
+	e.update(func(status *Status) {
+		status.Electing = true
+		status.Leader = true
+		status.Holder = status.Identity
+	})
+	run(ctx)
+}
+
+// Run takes part in the election of the Lease namespace/name and runs the
+// operator while leading. It exits the process when the lease is lost, so no
+// two replicas reconcile at once.
+func (e *Elector) Run(ctx context.Context, client kubernetes.Interface, namespace, name string, run func(ctx context.Context)) error {
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, namespace, name,
+		client.CoreV1(), client.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: e.Status().Identity})
+	if err != nil {
+		return err
+	}
+
+	e.update(func(status *Status) {
+		status.Electing = true
+	})
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				e.update(func(status *Status) {
+					status.Leader = true
+				})
+				run(ctx)
+			},
+			OnStoppedLeading: func() {
+				e.update(func(status *Status) {
+					status.Leader = false
+				})
+				klog.Fatalf("Lost leader election.")
+			},
+			OnNewLeader: func(identity string) {
+				e.update(func(status *Status) {
+					status.Holder = identity
+				})
+				klog.InfoS("New leader elected.", "identity", identity)
+			},
+		},
+	})
+	return nil
+}
+
+func (e *Elector) update(f func(status *Status)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	f(&e.status)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+	"k8s.io/klog/v2"
+)
+
+// throttleLogThreshold is the client-side rate limiter wait above which a
+// request is logged.
+var throttleLogThreshold = time.Second
+
+var (
+	rateLimiterLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mysql_operator_rest_client_rate_limiter_duration_seconds",
+		Help:    "Time requests to the API server waited for the client-side rate limiter.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"verb"})
+	requestResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mysql_operator_rest_client_requests_total",
+		Help: "Requests to the API server by status code and method.",
+	}, []string{"code", "method"})
+)
+
+// RegisterClientMetrics exposes the client-side throttling and the results of
+// the requests to the API server, and logs the requests slowed down by
+// either the client-side rate limiter or the server.
+func RegisterClientMetrics() {
+	MustRegister(rateLimiterLatency, requestResults)
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RateLimiterLatency: rateLimiterLatencyAdapter{},
+		RequestResult:      requestResultAdapter{},
+	})
+}
+
+type rateLimiterLatencyAdapter struct{}
+
+func (rateLimiterLatencyAdapter) Observe(_ context.Context, verb string, u url.URL, latency time.Duration) {
+	rateLimiterLatency.WithLabelValues(verb).Observe(latency.Seconds())
+	if latency >= throttleLogThreshold {
+		klog.InfoS("Request throttled by the client-side rate limiter, consider raising --kube-api-qps and --kube-api-burst.",
+			"verb", verb, "path", u.Path, "wait", latency)
+	}
+}
+
+type requestResultAdapter struct{}
+
+func (requestResultAdapter) Increment(_ context.Context, code, method, host string) {
+	requestResults.WithLabelValues(code, method).Inc()
+	if code == strconv.Itoa(http.StatusTooManyRequests) {
+		klog.InfoS("Request throttled by the API server.", "method", method, "host", host)
+	}
+}
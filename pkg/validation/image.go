@@ -0,0 +1,21 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/reference"
+)
+
+// ImageReferencePattern matches an image reference as accepted by the
+// container runtimes: [domain[:port]/]path[:tag][@digest]. It is the
+// expression of the reference library, for the admission policy.
+var ImageReferencePattern = reference.ReferenceRegexp.String()
+
+// ValidateImage returns an error if image is not a well-formed image
+// reference, as parsed by the container runtimes.
+func ValidateImage(image string) error {
+	if _, err := reference.ParseNormalizedNamed(image); err != nil {
+		return fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	return nil
+}
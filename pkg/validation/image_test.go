@@ -0,0 +1,35 @@
+package validation
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestValidateImage(t *testing.T) {
+	for _, tc := range []struct {
+		image string
+		valid bool
+	}{
+		{"mysql", true},
+		{"mysql:8.0", true},
+		{"registry.example.com:5000/db/mysql:8.0.36", true},
+		{"mysql@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", true},
+		{"mysql:8.0@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", true},
+		{"", false},
+		{"MySQL:8.0", false},
+		{"mysql:8.0:latest", false},
+		{"mysql@sha256:abc", false},
+		{"mysql:", false},
+		{"mysql 8.0", false},
+	} {
+		err := ValidateImage(tc.image)
+		if (err == nil) != tc.valid {
+			t.Errorf("ValidateImage(%q) = %v, want valid %t", tc.image, err, tc.valid)
+		}
+		// The admission policy checks the same syntax, but not the digest
+		// length.
+		if tc.valid && !regexp.MustCompile(ImageReferencePattern).MatchString(tc.image) {
+			t.Errorf("ImageReferencePattern does not match %q", tc.image)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Package validation holds the constraints on MySQL objects that the
+// structural schema of the CRD cannot express.
+package validation
+
+import (
+	"io"
+	"strconv"
+	"text/template"
+)
+
+// PolicyName is the name of the ValidatingAdmissionPolicy and of its binding.
+const PolicyName = "mysqls.volc.bytedance.com"
+
+// Rule is a constraint on MySQL objects, as a CEL expression over object.
+type Rule struct {
+	Expression string
+	Message    string
+}
+
+// Rules are the constraints enforced on MySQL objects.
+var Rules = []Rule{
+	{
+		Expression: "(has(object.spec.version) && object.spec.version != '') || (has(object.spec.image) && object.spec.image != '')",
+		Message:    "spec.version or spec.image is required",
+	},
+	{
+		Expression: "!has(object.spec.flavor) || object.spec.flavor in ['mysql', 'mariadb', 'percona']",
+		Message:    "spec.flavor must be mysql, mariadb or percona",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.flavor) ? object.spec.flavor : 'mysql') == (has(oldObject.spec.flavor) ? oldObject.spec.flavor : 'mysql')",
+		Message:    "spec.flavor is immutable",
+	},
+	{
+		Expression: "!has(object.spec.image) || object.spec.image.matches(r'" + ImageReferencePattern + "')",
+		Message:    "spec.image is not a valid image reference",
+	},
+	{
+		Expression: "!has(object.spec.podLabels) || !('app' in object.spec.podLabels)",
+		Message:    "spec.podLabels must not set the pod selector label app",
+	},
+	{
+		Expression: "!has(object.spec.pvcLabels) || !('app' in object.spec.pvcLabels)",
+		Message:    "spec.pvcLabels must not set the pod selector label app",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.pvcLabels) ? object.spec.pvcLabels : {}) == (has(oldObject.spec.pvcLabels) ? oldObject.spec.pvcLabels : {})",
+		Message:    "spec.pvcLabels is immutable",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.pvcAnnotations) ? object.spec.pvcAnnotations : {}) == (has(oldObject.spec.pvcAnnotations) ? oldObject.spec.pvcAnnotations : {})",
+		Message:    "spec.pvcAnnotations is immutable",
+	},
+	{
+		Expression: "!has(object.spec.sidecars) || object.spec.sidecars.all(c, !(c.name in ['mysql', 'exporter', 'seed']))",
+		Message:    "spec.sidecars must not use the names of the managed containers mysql, exporter and seed",
+	},
+	{
+		Expression: "!has(object.spec.metrics) || !has(object.spec.metrics.serviceMonitor) || !object.spec.metrics.serviceMonitor || (has(object.spec.metrics.enabled) && object.spec.metrics.enabled)",
+		Message:    "spec.metrics.serviceMonitor requires spec.metrics.enabled",
+	},
+	{
+		Expression: "!has(object.spec.ephemeral) || !object.spec.ephemeral || (!has(object.spec.storage) && !has(object.spec.binlogStorage))",
+		Message:    "spec.ephemeral excludes spec.storage and spec.binlogStorage",
+	},
+	{
+		Expression: "!has(object.spec.shutdownTimeout) || (duration(object.spec.shutdownTimeout) >= duration('1s') && duration(object.spec.shutdownTimeout) <= duration('1h'))",
+		Message:    "spec.shutdownTimeout must be between 1s and 1h",
+	},
+	{
+		Expression: "!has(object.spec.dataDir) || object.spec.dataDir.startsWith('/')",
+		Message:    "spec.dataDir must be an absolute path",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.dataDir) ? object.spec.dataDir : '/var/lib/mysql') == (has(oldObject.spec.dataDir) ? oldObject.spec.dataDir : '/var/lib/mysql')",
+		Message:    "spec.dataDir is immutable",
+	},
+	{
+		Expression: "!has(object.spec.dataSubPath) || (!object.spec.dataSubPath.startsWith('/') && !object.spec.dataSubPath.matches(r'(^|/)\\.\\.(/|$)'))",
+		Message:    "spec.dataSubPath must be a relative path within the volume",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.dataSubPath) ? object.spec.dataSubPath : '') == (has(oldObject.spec.dataSubPath) ? oldObject.spec.dataSubPath : '')",
+		Message:    "spec.dataSubPath is immutable",
+	},
+	{
+		Expression: "!has(object.spec.port) || object.spec.port != 9104",
+		Message:    "spec.port must not be the port 9104 of the metrics exporter",
+	},
+	{
+		Expression: "!has(object.spec.additionalServicePorts) || object.spec.additionalServicePorts.all(p, p.name != 'mysql' && p.port != (has(object.spec.port) ? object.spec.port : 3306) && !(has(object.spec.xProtocol) && has(object.spec.xProtocol.enabled) && object.spec.xProtocol.enabled && (p.name == 'mysqlx' || p.port == 33060)) && object.spec.additionalServicePorts.exists_one(q, q.name == p.name) && object.spec.additionalServicePorts.exists_one(q, q.port == p.port))",
+		Message:    "spec.additionalServicePorts must have unique names and ports, other than those of MySQL",
+	},
+	{
+		Expression: "!has(object.spec.xProtocol) || !has(object.spec.xProtocol.enabled) || !object.spec.xProtocol.enabled || ((!has(object.spec.flavor) || object.spec.flavor != 'mariadb') && (!has(object.spec.port) || object.spec.port != 33060))",
+		Message:    "spec.xProtocol is not supported by the mariadb flavor and needs spec.port to be another port than 33060",
+	},
+	{
+		Expression: "!has(object.spec.extraSecretMounts) || object.spec.extraSecretMounts.all(m, m.mountPath.startsWith('/') && [has(object.spec.dataDir) ? object.spec.dataDir : '/var/lib/mysql', '/var/lib/mysql-binlog', '/etc/mysql/conf.d', '/docker-entrypoint-initdb.d', '/var/log/mysql-audit'].all(p, m.mountPath != p && !m.mountPath.startsWith(p + '/') && !p.startsWith(m.mountPath + '/')) && object.spec.extraSecretMounts.exists_one(o, o.mountPath == m.mountPath))",
+		Message:    "spec.extraSecretMounts must mount at distinct absolute paths outside of the data, binlog, config, init script and audit log directories",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.ephemeral) && object.spec.ephemeral) == (has(oldObject.spec.ephemeral) && oldObject.spec.ephemeral)",
+		Message:    "spec.ephemeral is immutable",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.storage) && has(object.spec.storage.storageClassName) ? object.spec.storage.storageClassName : '') == (has(oldObject.spec.storage) && has(oldObject.spec.storage.storageClassName) ? oldObject.spec.storage.storageClassName : '')",
+		Message:    "spec.storage.storageClassName is immutable",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.binlogStorage) && has(object.spec.binlogStorage.storageClassName) ? object.spec.binlogStorage.storageClassName : '') == (has(oldObject.spec.binlogStorage) && has(oldObject.spec.binlogStorage.storageClassName) ? oldObject.spec.binlogStorage.storageClassName : '')",
+		Message:    "spec.binlogStorage.storageClassName is immutable",
+	},
+	{
+		Expression: "!has(object.spec.podManagementPolicy) || object.spec.podManagementPolicy in ['OrderedReady', 'Parallel']",
+		Message:    "spec.podManagementPolicy must be OrderedReady or Parallel",
+	},
+	{
+		Expression: "oldObject == null || (has(object.spec.podManagementPolicy) ? object.spec.podManagementPolicy : 'OrderedReady') == (has(oldObject.spec.podManagementPolicy) ? oldObject.spec.podManagementPolicy : 'OrderedReady')",
+		Message:    "spec.podManagementPolicy is immutable",
+	},
+	{
+		Expression: "!has(object.spec.gangScheduling) || !object.spec.gangScheduling || (has(object.spec.podManagementPolicy) && object.spec.podManagementPolicy == 'Parallel')",
+		Message:    "spec.gangScheduling requires spec.podManagementPolicy Parallel",
+	},
+	{
+		Expression: "!has(object.spec.deletionPolicy) || object.spec.deletionPolicy != 'Snapshot' || (has(object.spec.backup) && has(object.spec.backup.claimName) && object.spec.backup.claimName != '')",
+		Message:    "deletion policy Snapshot requires spec.backup.claimName",
+	},
+	{
+		Expression: "!has(object.spec.pvcRetentionPolicy) || !has(object.spec.pvcRetentionPolicy.whenDeleted) || object.spec.pvcRetentionPolicy.whenDeleted != 'Delete' || !has(object.spec.deletionPolicy) || object.spec.deletionPolicy != 'Retain'",
+		Message:    "spec.pvcRetentionPolicy.whenDeleted Delete contradicts deletion policy Retain",
+	},
+	{
+		Expression: "!has(object.spec.backup) || !has(object.spec.backup.snapshotClassName) || object.spec.backup.snapshotClassName == '' || (has(object.spec.backup.schedule) && object.spec.backup.schedule != '')",
+		Message:    "spec.backup.snapshotClassName requires spec.backup.schedule",
+	},
+	{
+		Expression: "!has(object.spec.upgrade) || !has(object.spec.upgrade.backupFirst) || !object.spec.upgrade.backupFirst || (has(object.spec.backup) && has(object.spec.backup.claimName) && object.spec.backup.claimName != '')",
+		Message:    "spec.upgrade.backupFirst requires spec.backup.claimName",
+	},
+}
+
+var policyTemplate = template.Must(template.New("policy").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicy
+metadata:
+  name: {{ .Name }}
+spec:
+  failurePolicy: Fail
+  matchConstraints:
+    resourceRules:
+    - apiGroups:
+      - volc.bytedance.com
+      apiVersions:
+      - v1alpha1
+      operations:
+      - CREATE
+      - UPDATE
+      resources:
+      - mysqls
+  validations:
+{{- range .Rules }}
+  - expression: {{ quote .Expression }}
+    message: {{ quote .Message }}
+{{- end }}
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingAdmissionPolicyBinding
+metadata:
+  name: {{ .Name }}
+spec:
+  policyName: {{ .Name }}
+  validationActions:
+  - Deny
+`))
+
+// WritePolicy writes a ValidatingAdmissionPolicy enforcing Rules, and its
+// binding, as YAML. It needs Kubernetes 1.30 or later.
+func WritePolicy(w io.Writer) error {
+	return policyTemplate.Execute(w, struct {
+		Name  string
+		Rules []Rule
+	}{PolicyName, Rules})
+}
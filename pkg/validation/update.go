@@ -0,0 +1,62 @@
+package validation
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	mysqlalpha1 "github.com/cyhw/mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// ValidateUpdate returns an error for each field changed from old to obj that
+// cannot change once the data is on the volumes. It enforces the immutability
+// rules of Rules in the validating webhook, for clusters that do not run the
+// admission policy.
+func ValidateUpdate(old, obj *mysqlalpha1.MySQL) []error {
+	var errs []error
+	immutable := func(field string, changed bool) {
+		if changed {
+			errs = append(errs, fmt.Errorf("%s is immutable", field))
+		}
+	}
+	spec, oldSpec := &obj.Spec, &old.Spec
+
+	immutable("spec.flavor", orDefault(string(spec.Flavor), string(mysqlalpha1.FlavorMySQL)) != orDefault(string(oldSpec.Flavor), string(mysqlalpha1.FlavorMySQL)))
+	immutable("spec.dataDir", orDefault(spec.DataDir, "/var/lib/mysql") != orDefault(oldSpec.DataDir, "/var/lib/mysql"))
+	immutable("spec.dataSubPath", spec.DataSubPath != oldSpec.DataSubPath)
+	immutable("spec.ephemeral", spec.Ephemeral != oldSpec.Ephemeral)
+	immutable("spec.podManagementPolicy", orDefault(string(spec.PodManagementPolicy), string(appsv1.OrderedReadyPodManagement)) != orDefault(string(oldSpec.PodManagementPolicy), string(appsv1.OrderedReadyPodManagement)))
+	immutable("spec.pvcLabels", !equalMaps(spec.PVCLabels, oldSpec.PVCLabels))
+	immutable("spec.pvcAnnotations", !equalMaps(spec.PVCAnnotations, oldSpec.PVCAnnotations))
+	immutable("spec.storage.storageClassName", storageClassName(&spec.Storage) != storageClassName(&oldSpec.Storage))
+	immutable("spec.binlogStorage.storageClassName", storageClassName(spec.BinlogStorage) != storageClassName(oldSpec.BinlogStorage))
+	return errs
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// equalMaps reports whether a and b hold the same entries, a nil map being
+// equal to an empty one.
+func equalMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+func storageClassName(storage *mysqlalpha1.MySQLStorageSpec) string {
+	if storage == nil || storage.StorageClassName == nil {
+		return ""
+	}
+	return *storage.StorageClassName
+}
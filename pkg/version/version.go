@@ -0,0 +1,45 @@
+// Package version holds the build information of the operator.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set through -ldflags "-X github.com/cyhw/mysql-operator/pkg/version.Version=...".
+var (
+	Version   = "dev"
+	GitCommit = ""
+)
+
+// Info is the build information of the running binary.
+type Info struct {
+	Version   string
+	GitCommit string
+	GoVersion string
+}
+
+// Get returns the build information, falling back to the VCS revision
+// recorded by the Go toolchain when GitCommit was not injected.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		GoVersion: runtime.Version(),
+	}
+	if info.GitCommit == "" {
+		if buildInfo, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range buildInfo.Settings {
+				if setting.Key == "vcs.revision" {
+					info.GitCommit = setting.Value
+				}
+			}
+		}
+	}
+	return info
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("version: %s, git commit: %s, go version: %s", i.Version, i.GitCommit, i.GoVersion)
+}